@@ -2,21 +2,161 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
-type ShutdownFunc func(ctx context.Context) error
+// App holds a command tree together with its config, components, workers and shutdown hooks.
+// The package level functions operate on a default App.
+type App struct {
+	rootCmd     *cobra.Command
+	subCommands []*cobra.Command
+	info        Info
+	args        []string
+	out         io.Writer
+	errOut      io.Writer
+
+	configFiles    []string
+	envFiles       []string
+	envPrefix      *string
+	configFlags    *configFlags
+	injectedConfig *viper.Viper
+	config         atomic.Pointer[viper.Viper]
+	loaded         atomic.Pointer[loadedConfig]
+	consul         *consulSource
+	configReload   bool
+	reloadMu       sync.Mutex
+
+	configMu          sync.Mutex
+	configValidators  []ConfigValidator
+	configSubscribers []configSubscriber
+	secretResolvers   map[string]SecretResolver
+	configTypes       configRegistry
+
+	shutdownMu      sync.Mutex
+	shutdownHooks   []shutdownHook
+	shutdownTimeout time.Duration
+
+	components *componentRegistry
+	workers    *supervisor
+
+	logLevels *logLevels
+
+	healthMu        sync.Mutex
+	readinessChecks map[string]HealthCheckFunc
+	livenessChecks  map[string]HealthCheckFunc
+	shuttingDown    atomic.Bool
+}
+
+type Option func(a *App)
+
+func WithRootCommand(cmd *cobra.Command) Option {
+	return func(a *App) {
+		a.rootCmd = cmd
+	}
+}
+
+func WithCommands(cmds ...*cobra.Command) Option {
+	return func(a *App) {
+		a.subCommands = append(a.subCommands, cmds...)
+	}
+}
+
+func WithInfo(i Info) Option {
+	return func(a *App) {
+		a.info = i
+	}
+}
+
+func WithConfigFile(file string) Option {
+	return func(a *App) {
+		a.SetConfigFile(file)
+	}
+}
+
+// WithConfigFiles sets config files merged in order, later files override earlier ones.
+func WithConfigFiles(files ...string) Option {
+	return func(a *App) {
+		a.SetConfigFiles(files...)
+	}
+}
 
-var shutdownFuncs = make([]ShutdownFunc, 0)
+// WithConfig makes the App use cfg instead of loading the config file.
+func WithConfig(cfg *viper.Viper) Option {
+	return func(a *App) {
+		a.injectedConfig = cfg
+	}
+}
 
-func OnShutdown(funcs ...ShutdownFunc) {
-	shutdownFuncs = append(shutdownFuncs, funcs...)
+// WithArgs sets the command line arguments, os.Args[1:] is used by default.
+func WithArgs(args ...string) Option {
+	return func(a *App) {
+		a.args = append([]string{}, args...)
+	}
 }
 
-func doubleKill() (context.Context, context.CancelFunc) {
+// WithOutput sets where the commands print their output and errors, os.Stdout and os.Stderr are used by default.
+func WithOutput(out, errOut io.Writer) Option {
+	return func(a *App) {
+		a.out = out
+		a.errOut = errOut
+	}
+}
+
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(a *App) {
+		a.shutdownTimeout = d
+	}
+}
+
+func New(opts ...Option) *App {
+	a := &App{
+		subCommands:     make([]*cobra.Command, 0),
+		shutdownHooks:   make([]shutdownHook, 0),
+		shutdownTimeout: defaultShutdownTimeout,
+		components:      newComponentRegistry(),
+		workers:         &supervisor{},
+		readinessChecks: make(map[string]HealthCheckFunc),
+		livenessChecks:  make(map[string]HealthCheckFunc),
+		secretResolvers: defaultSecretResolvers(),
+		logLevels:       &logLevels{},
+	}
+	registerConfig[consulConfig](a, "consul")
+	registerConfig[telemetryConfig](a, "telemetry")
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+var defaultApp = New()
+
+type appContextKey struct{}
+
+func contextWithApp(ctx context.Context, a *App) context.Context {
+	return context.WithValue(ctx, appContextKey{}, a)
+}
+
+// FromContext returns the App running the command of ctx, or the default App.
+func FromContext(ctx context.Context) *App {
+	if a, ok := ctx.Value(appContextKey{}).(*App); ok {
+		return a
+	}
+	return defaultApp
+}
+
+func doubleKill(onStop func()) (context.Context, context.CancelFunc) {
 	ch := make(chan os.Signal, 2)
 	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
 	ctx, stop := context.WithCancel(context.Background())
@@ -32,6 +172,7 @@ func doubleKill() (context.Context, context.CancelFunc) {
 					signal = "press Ctrl+C"
 				}
 				log.Printf("Waiting for application to stop gracefully, or %v again to terminate the application\n", signal)
+				onStop()
 				stop()
 			case 2:
 				log.Println("Terminating application")
@@ -43,19 +184,43 @@ func doubleKill() (context.Context, context.CancelFunc) {
 }
 
 func Run() {
-	appCtx, stop := doubleKill()
-	defer stop()
+	defaultApp.Run()
+}
 
-	err := executeCommand(appCtx)
+// Run executes the command with signal handling: the first interrupt stops the application gracefully,
+// the second one terminates it. The process exits with a non-zero code when Execute fails, see ExitCode.
+func (a *App) Run() {
+	appCtx, stop := doubleKill(a.markShuttingDown)
+	err := a.Execute(appCtx)
+	stop()
+
+	code := ExitCode(err)
 	if err != nil {
-		log.Println(err)
+		slog.Error("application exited", "exit_code", code, "error", err)
+		os.Exit(code)
 	}
+	slog.Info("application exited", "exit_code", code)
+}
 
-	shutdownCtx := context.TODO() // TODO should there be a mechanism to set this context, such as adding timeout, etc
-	for _, fn := range shutdownFuncs {
-		err = fn(shutdownCtx)
-		if err != nil {
-			log.Println(err)
-		}
+// Execute runs the command until it returns or ctx is done, then shuts the application down.
+// It returns the error of the command, or the error of the shutdown hooks wrapped in ErrShutdown.
+func (a *App) Execute(ctx context.Context) error {
+	appCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	a.workers.start(appCtx)
+	err := a.executeCommand(appCtx)
+
+	// stop the workers that are still running once the command returns
+	a.markShuttingDown()
+	stop()
+
+	shutdownErr := a.shutdown(a.shutdownDeadline())
+	if err != nil {
+		return err
+	}
+	if shutdownErr != nil {
+		return fmt.Errorf("%w: %w", ErrShutdown, shutdownErr)
 	}
+	return nil
 }
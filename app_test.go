@@ -0,0 +1,54 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+)
+
+func TestMultipleApps(t *testing.T) {
+	newApp := func(name string, calls *[]string) (*app.App, *bytes.Buffer) {
+		out := &bytes.Buffer{}
+		root := &cobra.Command{Use: name}
+		root.SetOut(out)
+		root.SetArgs([]string{"hello"})
+
+		a := app.New(app.WithRootCommand(root))
+		a.AddCommands(&cobra.Command{
+			Use: "hello",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if app.FromContext(cmd.Context()) != a {
+					t.Errorf("%v: expect app in command context", name)
+				}
+				cmd.Printf("hello from %v\n", name)
+				return nil
+			},
+		})
+		a.OnShutdown(func(ctx context.Context) error {
+			*calls = append(*calls, name)
+			return nil
+		})
+		return a, out
+	}
+
+	calls := make([]string, 0)
+	a1, out1 := newApp("first", &calls)
+	a2, out2 := newApp("second", &calls)
+
+	if err := a1.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := a2.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if out1.String() != "hello from first\n" || out2.String() != "hello from second\n" {
+		t.Errorf("unexpected output: %q, %q", out1.String(), out2.String())
+	}
+	if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+		t.Errorf("expect each app to run its own shutdown hooks, got %v", calls)
+	}
+}
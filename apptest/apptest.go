@@ -0,0 +1,235 @@
+// Package apptest executes commands of an app.App in-process, capturing their output and telemetry.
+package apptest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	slogmulti "github.com/samber/slog-multi"
+	"github.com/spf13/viper"
+	"github.com/yeka-go/app"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+	logsdk "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type Result struct {
+	Stdout string
+	Stderr string
+	// Logs is the text output of the default slog logger.
+	Logs string
+
+	Spans      tracetest.SpanStubs
+	Metrics    metricdata.ResourceMetrics
+	LogRecords []logsdk.Record
+
+	Err      error
+	ExitCode int
+}
+
+type Option func(o *options) error
+
+type options struct {
+	config *viper.Viper
+}
+
+// WithConfig injects config from a map, as if it was loaded from a config file.
+func WithConfig(cfg map[string]any) Option {
+	return func(o *options) error {
+		return o.config.MergeConfigMap(cfg)
+	}
+}
+
+// WithConfigYAML injects config from a YAML document, as if it was loaded from a config file.
+func WithConfigYAML(cfg string) Option {
+	return func(o *options) error {
+		o.config.SetConfigType("yaml")
+		return o.config.MergeConfig(strings.NewReader(cfg))
+	}
+}
+
+// Execute runs a with args, without signal handling, and returns once the application has shut down.
+// It replaces the global OpenTelemetry providers and the default slog logger during execution,
+// so tests using it must not run in parallel.
+func Execute(a *app.App, args []string, opts ...Option) *Result {
+	o := &options{config: viper.New()}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return &Result{Err: fmt.Errorf("apptest: %w", err), ExitCode: app.ExitCodeFailure}
+		}
+	}
+
+	stdout, stderr := &syncBuffer{}, &syncBuffer{}
+	tel := newMemoryTelemetry()
+	app.WithArgs(args...)(a)
+	app.WithConfig(o.config)(a)
+	app.WithOutput(stdout, stderr)(a)
+	if err := a.Register(app.TelemetryComponent, tel); err != nil {
+		return &Result{Err: fmt.Errorf("apptest: %w", err), ExitCode: app.ExitCodeFailure}
+	}
+
+	restore := capture(&os.Stdout, stdout)
+	restoreErr := capture(&os.Stderr, stderr)
+	err := a.Execute(context.Background())
+	restoreErr()
+	restore()
+
+	res := &Result{
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		Logs:       tel.logs.String(),
+		Spans:      tel.spanStubs,
+		Metrics:    tel.metrics,
+		LogRecords: tel.records.get(),
+		Err:        err,
+		ExitCode:   app.ExitCode(err),
+	}
+	return res
+}
+
+// syncBuffer is written by the command and by the goroutine copying what is written to os.Stdout or os.Stderr.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// capture redirects *f into w until the returned func is called.
+func capture(f **os.File, w io.Writer) func() {
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	orig := *f
+	*f = pw
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(w, r)
+		close(done)
+	}()
+	return func() {
+		*f = orig
+		_ = pw.Close()
+		<-done
+		_ = r.Close()
+	}
+}
+
+// memoryTelemetry is a telemetry component keeping everything in memory.
+type memoryTelemetry struct {
+	logs    *bytes.Buffer
+	spans   *tracetest.InMemoryExporter
+	reader  *metric.ManualReader
+	records *memoryLogExporter
+
+	spanStubs tracetest.SpanStubs
+	metrics   metricdata.ResourceMetrics
+
+	tracerProvider *trace.TracerProvider
+	meterProvider  *metric.MeterProvider
+	loggerProvider *logsdk.LoggerProvider
+	restore        func()
+}
+
+func newMemoryTelemetry() *memoryTelemetry {
+	return &memoryTelemetry{
+		logs:    &bytes.Buffer{},
+		spans:   tracetest.NewInMemoryExporter(),
+		reader:  metric.NewManualReader(),
+		records: &memoryLogExporter{},
+	}
+}
+
+func (t *memoryTelemetry) Start(ctx context.Context) error {
+	t.tracerProvider = trace.NewTracerProvider(trace.WithSyncer(t.spans))
+	t.meterProvider = metric.NewMeterProvider(metric.WithReader(t.reader))
+	t.loggerProvider = logsdk.NewLoggerProvider(logsdk.WithProcessor(logsdk.NewSimpleProcessor(t.records)))
+
+	prevTracer, prevMeter, prevLogger, prevSlog := otel.GetTracerProvider(), otel.GetMeterProvider(), global.GetLoggerProvider(), slog.Default()
+	t.restore = func() {
+		otel.SetTracerProvider(prevTracer)
+		otel.SetMeterProvider(prevMeter)
+		global.SetLoggerProvider(prevLogger)
+		slog.SetDefault(prevSlog)
+	}
+
+	otel.SetTracerProvider(t.tracerProvider)
+	otel.SetMeterProvider(t.meterProvider)
+	global.SetLoggerProvider(t.loggerProvider)
+	slog.SetDefault(slog.New(slogmulti.Fanout(
+		slog.NewTextHandler(t.logs, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		otelslog.NewHandler("github.com/yeka-go/app", otelslog.WithLoggerProvider(t.loggerProvider)),
+	)))
+	return nil
+}
+
+func (t *memoryTelemetry) Stop(ctx context.Context) error {
+	defer t.restore()
+	errs := []error{
+		t.tracerProvider.ForceFlush(ctx),
+		t.reader.Collect(ctx, &t.metrics),
+	}
+	// the in-memory span exporter forgets its spans on shutdown
+	t.spanStubs = t.spans.GetSpans()
+	errs = append(errs,
+		t.tracerProvider.Shutdown(ctx),
+		t.meterProvider.Shutdown(ctx),
+		t.loggerProvider.Shutdown(ctx),
+	)
+	return errors.Join(errs...)
+}
+
+func (t *memoryTelemetry) Health(ctx context.Context) error {
+	return nil
+}
+
+type memoryLogExporter struct {
+	mu      sync.Mutex
+	records []logsdk.Record
+}
+
+func (e *memoryLogExporter) Export(ctx context.Context, records []logsdk.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (e *memoryLogExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *memoryLogExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+func (e *memoryLogExporter) get() []logsdk.Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]logsdk.Record(nil), e.records...)
+}
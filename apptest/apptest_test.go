@@ -0,0 +1,98 @@
+package apptest_test
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+	"github.com/yeka-go/app/apptest"
+	"go.opentelemetry.io/otel"
+)
+
+func newApp() *app.App {
+	return app.New(app.WithRootCommand(&cobra.Command{Use: "test"}), app.WithCommands(newGreetCommand()))
+}
+
+func newGreetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use: "greet <name>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, span := otel.Tracer("test").Start(cmd.Context(), "greet")
+			defer span.End()
+
+			cfg := app.ConfigFromContext(cmd.Context())
+			if len(args) == 0 {
+				return errors.New("name is required")
+			}
+			slog.InfoContext(cmd.Context(), "greeting", "name", args[0])
+			cmd.Printf("%s, %s!\n", cfg.GetString("greeting"), args[0])
+			fmt.Println("printed to stdout")
+			return nil
+		},
+	}
+}
+
+func TestExecute(t *testing.T) {
+	testData := []struct {
+		Args     []string
+		Option   apptest.Option
+		Stdout   string
+		ExitCode int
+	}{
+		{[]string{"greet", "John"}, apptest.WithConfig(map[string]any{"greeting": "Hello"}), "Hello, John!\nprinted to stdout\n", 0},
+		{[]string{"greet", "Jane"}, apptest.WithConfigYAML("greeting: Hi"), "Hi, Jane!\nprinted to stdout\n", 0},
+		{[]string{"greet"}, apptest.WithConfigYAML("greeting: Hi"), "", 1},
+	}
+	for i, v := range testData {
+		res := apptest.Execute(newApp(), v.Args, v.Option)
+		if res.Stdout != v.Stdout {
+			t.Errorf("\nscenario #%v, expect stdout %q, got %q", i+1, v.Stdout, res.Stdout)
+		}
+		if res.ExitCode != v.ExitCode {
+			t.Errorf("\nscenario #%v, expect exit code %v, got %v (%v)", i+1, v.ExitCode, res.ExitCode, res.Err)
+		}
+		if len(res.Spans) != 1 || res.Spans[0].Name != "greet" {
+			t.Errorf("\nscenario #%v, expect greet span, got %v", i+1, res.Spans)
+		}
+		if v.ExitCode == 0 && len(res.LogRecords) == 0 {
+			t.Errorf("\nscenario #%v, expect log records", i+1)
+		}
+	}
+
+	res := apptest.Execute(newApp(), []string{"greet", "John"})
+	if res.Logs == "" {
+		t.Error("expect slog output to be captured")
+	}
+}
+
+func TestExecuteSharedCommand(t *testing.T) {
+	root := &cobra.Command{Use: "test"}
+	root.AddCommand(newGreetCommand())
+	testData := []struct {
+		Args   []string
+		Stdout string
+	}{
+		{[]string{"greet", "John", "--profile", "dev", "--log-level", "debug"}, "Hello, John!\nprinted to stdout\n"},
+		{[]string{"greet", "Jane"}, "Hello, Jane!\nprinted to stdout\n"},
+	}
+	for i, v := range testData {
+		res := apptest.Execute(app.New(app.WithRootCommand(root)), v.Args, apptest.WithConfigYAML("greeting: Hello"))
+		if res.Err != nil {
+			t.Fatalf("\nscenario #%v, expect no error, got %v", i+1, res.Err)
+		}
+		if res.Stdout != v.Stdout {
+			t.Errorf("\nscenario #%v, expect stdout %q, got %q", i+1, v.Stdout, res.Stdout)
+		}
+		if len(res.Spans) != 1 {
+			t.Errorf("\nscenario #%v, expect one span, got %v", i+1, res.Spans)
+		}
+	}
+	for _, cmd := range root.Commands() {
+		if cmd.Name() == "version" || cmd.Name() == "config" {
+			t.Errorf("expect the %s command to be removed once executed", cmd.Name())
+		}
+	}
+}
@@ -8,61 +8,154 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
-var rootCmd *cobra.Command
-
-var subCommands = make([]*cobra.Command, 0)
-
 func SetRootCommand(cmd *cobra.Command) {
-	rootCmd = cmd
+	defaultApp.SetRootCommand(cmd)
 }
 
 func AddCommands(cmds ...*cobra.Command) {
-	subCommands = append(subCommands, cmds...)
+	defaultApp.AddCommands(cmds...)
 }
 
-func executeCommand(appCtx context.Context) error {
-	if rootCmd == nil {
-		rootCmd = &cobra.Command{
-			Use:   "app",                    // TODO changeable
-			Short: "This is my application", // TODO changeable
-		}
-	}
+func (a *App) SetRootCommand(cmd *cobra.Command) {
+	a.rootCmd = cmd
+}
 
-	cfgFile := ""
-	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "configuration file")
-	rootCmd.CompletionOptions.DisableDefaultCmd = true
-	rootCmd.PersistentPreRunE = preRun(&cfgFile, rootCmd.PersistentPreRun, rootCmd.PersistentPreRunE)
-	rootCmd.SetContext(appCtx)
+func (a *App) AddCommands(cmds ...*cobra.Command) {
+	a.subCommands = append(a.subCommands, cmds...)
+}
 
-	rootCmd.AddCommand(subCommands...)
+// executeCommand executes the root command. The command tree is left as it was found once it returns,
+// apart from the persistent flags, so it may be executed again by another App.
+func (a *App) executeCommand(appCtx context.Context) (err error) {
 	defer func() {
 		r := recover()
 		if r != nil {
-			slog.Error(fmt.Sprintf("%+v\n", r))
-			stack := strings.Split(string(debug.Stack()), "\n")
-			stack = append([]string{stack[0]}, stack[7:]...)
-			fmt.Printf("%s\n", strings.Join(stack, "\n"))
+			logPanic(r)
+			err = fmt.Errorf("%w: %v", ErrPanic, r)
 		}
 	}()
+
+	if a.rootCmd == nil {
+		i := a.appInfo()
+		a.rootCmd = &cobra.Command{
+			Use:   i.Name,
+			Short: i.Description,
+		}
+	}
+	rootCmd := a.rootCmd
+
+	addConfigFlags(rootCmd.PersistentFlags())
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	preRunE := rootCmd.PersistentPreRunE
+	rootCmd.PersistentPreRunE = a.preRun(rootCmd.PersistentPreRun, preRunE)
+	defer func() { rootCmd.PersistentPreRunE = preRunE }()
+	rootCmd.SetContext(contextWithApp(appCtx, a))
+	if a.args != nil {
+		rootCmd.SetArgs(a.args)
+	}
+	if a.out != nil {
+		rootCmd.SetOut(a.out)
+	}
+	if a.errOut != nil {
+		rootCmd.SetErr(a.errOut)
+	}
+
+	rootCmd.AddCommand(a.subCommands...)
+	defer rootCmd.RemoveCommand(a.subCommands...)
+	var builtins []*cobra.Command
+	if !hasCommand(rootCmd, "version") {
+		builtins = append(builtins, a.versionCommand())
+	}
+	if !hasCommand(rootCmd, "config") {
+		builtins = append(builtins, a.configCommand())
+	}
+	rootCmd.AddCommand(builtins...)
+	defer rootCmd.RemoveCommand(builtins...)
 	return rootCmd.Execute()
 }
 
-func preRun(cfgFile *string, runFn func(cmd *cobra.Command, args []string), runErrFn func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+// addConfigFlags adds the flags of the config to the persistent flags of the root command,
+// or resets them when an App already executed the command.
+func addConfigFlags(flags *pflag.FlagSet) {
+	if flags.Lookup("config") != nil {
+		for _, name := range []string{"config", "profile", "env-file", "log-level"} {
+			f := flags.Lookup(name)
+			if v, ok := f.Value.(pflag.SliceValue); ok {
+				_ = v.Replace(nil)
+			} else {
+				_ = f.Value.Set(f.DefValue)
+			}
+			f.Changed = false
+		}
+		return
+	}
+	flags.StringSliceP("config", "c", nil, "configuration file, can be repeated")
+	flags.String("profile", "", "configuration profile, default to $APP_PROFILE")
+	flags.StringSlice("env-file", nil, "dotenv file, can be repeated")
+	flags.String("log-level", "", "log level: debug, info, warn or error, default to telemetry.logger.level")
+}
+
+// configFlagsOf returns the values of the config flags given to cmd.
+func configFlagsOf(cmd *cobra.Command) *configFlags {
+	flags := cmd.Root().PersistentFlags()
+	c := &configFlags{}
+	c.configFiles, _ = flags.GetStringSlice("config")
+	c.envFiles, _ = flags.GetStringSlice("env-file")
+	c.profile, _ = flags.GetString("profile")
+	c.logLevel, _ = flags.GetString("log-level")
+	return c
+}
+
+// logPanic logs r and prints the stack trace of the panicking goroutine.
+// It must be called directly from the deferred function that recovered the panic.
+func logPanic(r any) {
+	slog.Error(fmt.Sprintf("%+v\n", r))
+	// skip the frames of debug.Stack, logPanic, the deferred function and runtime.gopanic
+	stack := strings.Split(string(debug.Stack()), "\n")
+	stack = append([]string{stack[0]}, stack[9:]...)
+	fmt.Printf("%s\n", strings.Join(stack, "\n"))
+}
+
+func hasCommand(cmd *cobra.Command, name string) bool {
+	for _, c := range cmd.Commands() {
+		if c.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) preRun(runFn func(cmd *cobra.Command, args []string), runErrFn func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
 	return func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
-		if err := initConfig(*cfgFile); err != nil {
-			return err
+		flags := configFlagsOf(cmd)
+		if err := a.initConfig(cmd.Context(), flags); err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+		if err := a.watchConfig(); err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
 		}
 
-		if config != nil {
-			cmd.SetContext(contextWithConfig(cmd.Context()))
-			err := initTelemetry(config)
-			if err != nil {
+		cmd.SetContext(contextWithConfig(cmd.Context(), &a.config))
+		cfg := a.config.Load()
+
+		// a telemetry component registered beforehand replaces the one configured from config
+		if _, ok := a.LookupComponent(TelemetryComponent); !ok {
+			if err := a.Register(TelemetryComponent, &telemetry{config: cfg, info: a.appInfo(), levels: a.logLevels, logLevel: flags.logLevel}); err != nil {
 				return err
 			}
 		}
+		if cfg.GetString("admin.addr") != "" {
+			if err := a.Register(AdminComponent, a.newAdmin(cfg.GetString("admin.addr")), TelemetryComponent); err != nil {
+				return err
+			}
+		}
+		if err := a.components.start(cmd.Context()); err != nil {
+			return err
+		}
 
 		if runErrFn != nil {
 			return runErrFn(cmd, args)
@@ -0,0 +1,263 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Component is a long-lived part of the application, such as a database connection, a server or a worker.
+// Components are started in dependency order before the command runs and stopped in reverse order on exit.
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	Health(ctx context.Context) error
+}
+
+type componentEntry struct {
+	name      string
+	component Component
+	dependsOn []string
+	started   bool
+	// starting is closed once the component registered while the application runs is started, or failed to
+	starting chan struct{}
+	startErr error
+}
+
+type componentRegistry struct {
+	mu      sync.Mutex
+	entries []*componentEntry
+	byName  map[string]*componentEntry
+	started []*componentEntry
+	running bool
+	ctx     context.Context
+}
+
+func newComponentRegistry() *componentRegistry {
+	return &componentRegistry{byName: make(map[string]*componentEntry)}
+}
+
+// Register adds a component to the application. Components registered while the application is running
+// are started immediately, their dependencies must already be running.
+func Register(name string, c Component, dependsOn ...string) error {
+	return defaultApp.Register(name, c, dependsOn...)
+}
+
+// Health returns the result of the health check of every running component, keyed by component name.
+func Health(ctx context.Context) map[string]error {
+	return defaultApp.Health(ctx)
+}
+
+// LoadOrRegister returns the component registered under name, or registers c when there is none.
+// The loaded result is true when a component was already registered. A component being started by a concurrent
+// call is returned once started.
+func LoadOrRegister(name string, c Component, dependsOn ...string) (Component, bool, error) {
+	return defaultApp.LoadOrRegister(name, c, dependsOn...)
+}
+
+// LookupComponent returns a registered component by its name.
+func LookupComponent(name string) (Component, bool) {
+	return defaultApp.LookupComponent(name)
+}
+
+func (a *App) Register(name string, c Component, dependsOn ...string) error {
+	return a.components.register(name, c, dependsOn...)
+}
+
+func (a *App) LoadOrRegister(name string, c Component, dependsOn ...string) (Component, bool, error) {
+	return a.components.loadOrRegister(name, c, dependsOn...)
+}
+
+func (a *App) Health(ctx context.Context) map[string]error {
+	return a.components.health(ctx)
+}
+
+func (a *App) LookupComponent(name string) (Component, bool) {
+	return a.components.lookup(name)
+}
+
+func (r *componentRegistry) register(name string, c Component, dependsOn ...string) error {
+	r.mu.Lock()
+	if _, ok := r.byName[name]; ok {
+		r.mu.Unlock()
+		return errors.New("component already registered: " + name)
+	}
+	return r.add(name, c, dependsOn)
+}
+
+func (r *componentRegistry) loadOrRegister(name string, c Component, dependsOn ...string) (Component, bool, error) {
+	r.mu.Lock()
+	e, ok := r.byName[name]
+	if !ok {
+		return c, false, r.add(name, c, dependsOn)
+	}
+	starting := e.starting
+	r.mu.Unlock()
+	if starting != nil {
+		<-starting
+		if e.startErr != nil {
+			return nil, true, e.startErr
+		}
+	}
+	return e.component, true, nil
+}
+
+// add adds the component, r.mu must be locked and it is unlocked once the component is added,
+// the component is started when the application is running.
+func (r *componentRegistry) add(name string, c Component, dependsOn []string) error {
+	e := &componentEntry{name: name, component: c, dependsOn: dependsOn}
+	r.entries = append(r.entries, e)
+	r.byName[name] = e
+	running, ctx := r.running, r.ctx
+	if running {
+		for _, dep := range dependsOn {
+			if d, ok := r.byName[dep]; !ok || !d.started {
+				r.remove(e)
+				r.mu.Unlock()
+				return fmt.Errorf("component %s depends on %s which is not running", name, dep)
+			}
+		}
+		e.starting = make(chan struct{})
+	}
+	r.mu.Unlock()
+
+	if !running {
+		return nil
+	}
+	err := r.startEntry(ctx, e)
+	if err != nil {
+		r.mu.Lock()
+		r.remove(e)
+		r.mu.Unlock()
+	}
+	e.startErr = err
+	close(e.starting)
+	return err
+}
+
+func (r *componentRegistry) remove(e *componentEntry) {
+	delete(r.byName, e.name)
+	for i, v := range r.entries {
+		if v == e {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			break
+		}
+	}
+}
+
+func (r *componentRegistry) lookup(name string) (Component, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.byName[name]
+	if !ok {
+		return nil, false
+	}
+	return e.component, true
+}
+
+// start starts every registered component in topological order.
+func (r *componentRegistry) start(ctx context.Context) error {
+	r.mu.Lock()
+	order, err := r.order()
+	r.ctx = ctx
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range order {
+		if e.started {
+			continue
+		}
+		if err := r.startEntry(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	r.running = true
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *componentRegistry) startEntry(ctx context.Context, e *componentEntry) error {
+	if err := e.component.Start(ctx); err != nil {
+		return fmt.Errorf("component %s: %w", e.name, err)
+	}
+	r.mu.Lock()
+	e.started = true
+	r.started = append(r.started, e)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *componentRegistry) order() ([]*componentEntry, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	order := make([]*componentEntry, 0, len(r.entries))
+
+	var visit func(e *componentEntry, path []string) error
+	visit = func(e *componentEntry, path []string) error {
+		path = append(path, e.name)
+		switch state[e.name] {
+		case visiting:
+			return errors.New("component dependency cycle: " + strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[e.name] = visiting
+		for _, dep := range e.dependsOn {
+			d, ok := r.byName[dep]
+			if !ok {
+				return fmt.Errorf("component %s depends on unknown component %s", e.name, dep)
+			}
+			if err := visit(d, path); err != nil {
+				return err
+			}
+		}
+		state[e.name] = visited
+		order = append(order, e)
+		return nil
+	}
+
+	for _, e := range r.entries {
+		if err := visit(e, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// shutdownSteps returns one step per started component, in reverse start order.
+func (r *componentRegistry) shutdownSteps() [][]shutdownHook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	steps := make([][]shutdownHook, 0, len(r.started))
+	for i := len(r.started) - 1; i >= 0; i-- {
+		e := r.started[i]
+		e.started = false
+		steps = append(steps, []shutdownHook{{name: "component " + e.name, fn: e.component.Stop}})
+	}
+	r.started = nil
+	r.running = false
+	return steps
+}
+
+// health returns the health of every started component.
+func (r *componentRegistry) health(ctx context.Context) map[string]error {
+	r.mu.Lock()
+	started := append([]*componentEntry(nil), r.started...)
+	r.mu.Unlock()
+
+	res := make(map[string]error, len(started))
+	for _, e := range started {
+		res[e.name] = e.component.Health(ctx)
+	}
+	return res
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeComponent struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (c *fakeComponent) Start(ctx context.Context) error {
+	*c.calls = append(*c.calls, "start "+c.name)
+	return c.err
+}
+
+func (c *fakeComponent) Stop(ctx context.Context) error {
+	*c.calls = append(*c.calls, "stop "+c.name)
+	return nil
+}
+
+func (c *fakeComponent) Health(ctx context.Context) error {
+	return c.err
+}
+
+func TestComponentRegistry(t *testing.T) {
+	calls := make([]string, 0)
+	r := newComponentRegistry()
+	for _, v := range []struct {
+		name string
+		deps []string
+	}{
+		{"server", []string{"db", "cache"}},
+		{"db", []string{"telemetry"}},
+		{"cache", nil},
+		{"telemetry", nil},
+	} {
+		if err := r.register(v.name, &fakeComponent{name: v.name, calls: &calls}, v.deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.register("db", &fakeComponent{}); err == nil {
+		t.Error("expect duplicate registration to fail")
+	}
+
+	if err := r.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.register("worker", &fakeComponent{name: "worker", calls: &calls}, "db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.register("orphan", &fakeComponent{name: "orphan", calls: &calls}, "unknown"); err == nil {
+		t.Error("expect late registration with unknown dependency to fail")
+	}
+	if err := r.register("broken", &fakeComponent{name: "broken", calls: &calls, err: errors.New("failure")}); err == nil {
+		t.Error("expect late registration to return start error")
+	}
+	if _, ok := r.lookup("broken"); ok {
+		t.Error("expect failed component to be removed")
+	}
+
+	for _, step := range r.shutdownSteps() {
+		runShutdownStep(context.Background(), step)
+	}
+
+	expect := "start telemetry,start db,start cache,start server,start worker,start broken," +
+		"stop worker,stop server,stop cache,stop db,stop telemetry"
+	if got := strings.Join(calls, ","); got != expect {
+		t.Errorf("\nexpect %v\ngot    %v", expect, got)
+	}
+}
+
+func TestComponentCycle(t *testing.T) {
+	calls := make([]string, 0)
+	r := newComponentRegistry()
+	_ = r.register("a", &fakeComponent{name: "a", calls: &calls}, "b")
+	_ = r.register("b", &fakeComponent{name: "b", calls: &calls}, "a")
+
+	err := r.start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Errorf("expect dependency cycle error, got %v", err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("expect nothing started, got %v", calls)
+	}
+}
+
+type slowComponent struct {
+	starts *atomic.Int32
+}
+
+func (c *slowComponent) Start(ctx context.Context) error {
+	c.starts.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	return nil
+}
+
+func (c *slowComponent) Stop(ctx context.Context) error   { return nil }
+func (c *slowComponent) Health(ctx context.Context) error { return nil }
+
+func TestComponentLoadOrRegister(t *testing.T) {
+	r := newComponentRegistry()
+	if err := r.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	starts := &atomic.Int32{}
+	results := make([]Component, 10)
+	errs := make([]error, 10)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _, errs[i] = r.loadOrRegister("db", &slowComponent{starts: starts})
+		}()
+	}
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil || results[i] != results[0] {
+			t.Errorf("\nscenario #%v, expect the registered component, got %v (%v)", i+1, results[i], errs[i])
+		}
+	}
+	if n := starts.Load(); n != 1 {
+		t.Errorf("expect the component to be started once, got %v", n)
+	}
+}
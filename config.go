@@ -2,40 +2,174 @@ package app
 
 import (
 	"context"
-	"log/slog"
+	"sync/atomic"
 
 	"github.com/spf13/viper"
 )
 
-var config *viper.Viper
-
-var configFile string
-
 type configContextKey struct{}
 
 func SetConfigFile(file string) {
-	configFile = file
+	defaultApp.SetConfigFile(file)
+}
+
+// SetConfigFiles sets config files merged in order, later files override earlier ones.
+func SetConfigFiles(files ...string) {
+	defaultApp.SetConfigFiles(files...)
+}
+
+func (a *App) SetConfigFile(file string) {
+	a.configFiles = []string{file}
+}
+
+func (a *App) SetConfigFiles(files ...string) {
+	a.configFiles = append([]string{}, files...)
+}
+
+type configFlags struct {
+	configFiles []string
+	envFiles    []string
+	profile     string
+	logLevel    string
 }
 
-func initConfig(cfgFile string) error {
-	file := configFile
-	if cfgFile != "" {
-		file = cfgFile
+// initConfig loads the config and makes it available through ConfigFromContext.
+func (a *App) initConfig(ctx context.Context, flags *configFlags) error {
+	a.configFlags = flags
+	l, err := a.loadConfig(ctx)
+	if err != nil {
+		return err
 	}
-	if file == "" {
-		slog.DebugContext(context.TODO(), "No config loaded")
-		return nil
+	if err := a.validateConfig(l.config); err != nil {
+		return err
+	}
+	a.storeConfig(l)
+	a.consul = l.consul
+	return nil
+}
+
+// loadedConfig is a config together with the sources it was built from.
+type loadedConfig struct {
+	config *viper.Viper
+	consul *consulSource
+	// layers are the settings of each source, from the lowest to the highest precedence
+	layers []configLayer
+	// env holds the variables of the dotenv files
+	env map[string]string
+	// files are the config files read, including the profile files and the included files
+	files []string
+	// secrets are the keys holding resolved secrets
+	secrets []string
+}
+
+type configLayer struct {
+	source   string
+	file     string
+	settings map[string]any
+}
+
+func (a *App) storeConfig(l *loadedConfig) *viper.Viper {
+	a.loaded.Store(l)
+	return a.config.Swap(l.config)
+}
+
+// loadConfig builds a new config, from the lowest to the highest precedence:
+// dotenv files, the config files, consul, then environment variables.
+func (a *App) loadConfig(ctx context.Context) (*loadedConfig, error) {
+	flags := a.configFlags
+	env, err := a.loadEnvFiles(flags.envFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := a.readConfigFiles(flags.configFiles, a.configProfile())
+	if err != nil {
+		return nil, err
+	}
+	file := viper.New()
+	for _, layer := range files {
+		if err := file.MergeConfigMap(layer.settings); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := file.AllKeys()
+	dotenv := make(map[string]any)
+	for name, value := range env {
+		setPath(dotenv, envKey(name, keys), value)
+	}
+	overrides := configLayer{source: "environment", settings: a.envOverrides(keys)}
+	l := &loadedConfig{
+		config: viper.New(),
+		env:    env,
+		layers: append(append([]configLayer{{source: "dotenv", settings: dotenv}}, files...), overrides),
+	}
+	for _, layer := range files {
+		if layer.file != "" {
+			l.files = append(l.files, layer.file)
+		}
+	}
+
+	// every source is merged into the same layer, so nested keys from different sources are combined
+	for _, layer := range l.layers {
+		if err := l.config.MergeConfigMap(layer.settings); err != nil {
+			return nil, err
+		}
 	}
-	config = viper.New()
-	config.SetConfigFile(file)
-	return config.ReadInConfig()
+
+	// the consul settings are needed before the secrets of the whole config, eg: a token from a secret store
+	consul := viper.New()
+	if l.config.IsSet("consul") {
+		if err := consul.MergeConfigMap(map[string]any{"consul": l.config.Get("consul")}); err != nil {
+			return nil, err
+		}
+	}
+	consul, _, err = a.resolveSecrets(ctx, consul)
+	if err != nil {
+		return nil, err
+	}
+	l.consul, err = newConsulSource(consul)
+	if err != nil {
+		return nil, err
+	}
+	if l.consul != nil {
+		kv, index, err := l.consul.fetch(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		l.consul.kv, l.consul.index = kv, index
+		// environment variables still take precedence over consul, and may override its keys too
+		consulLayer := configLayer{source: "consul " + l.consul.prefix, settings: kv}
+		fromConsul := viper.New()
+		if err := fromConsul.MergeConfigMap(kv); err != nil {
+			return nil, err
+		}
+		overrides.settings = a.envOverrides(append(keys, fromConsul.AllKeys()...))
+		l.layers = append(l.layers[:len(l.layers)-1], consulLayer, overrides)
+		for _, layer := range l.layers[len(l.layers)-2:] {
+			if err := l.config.MergeConfigMap(layer.settings); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	l.config, l.secrets, err = a.resolveSecrets(ctx, l.config)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
 }
 
-func contextWithConfig(ctx context.Context) context.Context {
+func contextWithConfig(ctx context.Context, config *atomic.Pointer[viper.Viper]) context.Context {
 	return context.WithValue(ctx, configContextKey{}, config)
 }
 
+// ConfigFromContext returns the current config. The returned value may be replaced when the config is reloaded,
+// so it should be retrieved again rather than kept.
 func ConfigFromContext(ctx context.Context) *viper.Viper {
-	cfg, _ := ctx.Value(configContextKey{}).(*viper.Viper)
-	return cfg
+	cfg, _ := ctx.Value(configContextKey{}).(*atomic.Pointer[viper.Viper])
+	if cfg == nil {
+		return nil
+	}
+	return cfg.Load()
 }
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+// configCommand returns the commands to inspect the config. The config is loaded without being validated,
+// and the components are not started.
+func (a *App) configCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+		Args:  cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			a.configFlags = configFlagsOf(cmd)
+			l, err := a.loadConfig(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrConfig, err)
+			}
+			a.storeConfig(l)
+			cmd.SetContext(contextWithConfig(cmd.Context(), &a.config))
+			return nil
+		},
+	}
+	cmd.AddCommand(a.configShowCommand(), a.configValidateCommand(), a.configExplainCommand(), a.configSchemaCommand())
+	return cmd
+}
+
+func (a *App) configShowCommand() *cobra.Command {
+	output := "yaml"
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective config, with secrets redacted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings := a.redactConfig(a.config.Load())
+			return writeFormatted(cmd.OutOrStdout(), output, settings)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "yaml", "output format: yaml, json")
+	return cmd
+}
+
+func (a *App) configValidateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the config with the registered config types and validators",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := a.validateConfig(a.config.Load())
+			if err == nil {
+				fmt.Fprintln(cmd.OutOrStdout(), "config is valid")
+				return nil
+			}
+
+			// the violations are listed one per line instead of the single line error printed by cobra
+			cmd.SilenceErrors = true
+			fmt.Fprintln(cmd.ErrOrStderr(), "config is invalid:")
+			for _, msg := range violations(err) {
+				fmt.Fprintf(cmd.ErrOrStderr(), "  %s\n", msg)
+			}
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		},
+	}
+}
+
+// violations flattens the errors joined by validateConfig.
+func violations(err error) []string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var msgs []string
+		for _, err := range joined.Unwrap() {
+			msgs = append(msgs, violations(err)...)
+		}
+		return msgs
+	}
+	var verr *ConfigValidationError
+	if !errors.As(err, &verr) {
+		return []string{err.Error()}
+	}
+	msgs := make([]string, 0, len(verr.Violations))
+	for _, v := range verr.Violations {
+		msgs = append(msgs, strings.TrimPrefix(v.Key+": "+v.Message, ": "))
+	}
+	return msgs
+}
+
+func (a *App) configExplainCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain <key>",
+		Short: "Show which source supplied the value of a config key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := strings.ToLower(args[0])
+			l := a.loaded.Load()
+			out := cmd.OutOrStdout()
+
+			var sources []string
+			for i := len(l.layers) - 1; i >= 0; i-- {
+				if _, ok := lookupPath(l.layers[i].settings, key); ok {
+					sources = append(sources, a.describeSource(l, l.layers[i].source, key))
+				}
+			}
+			if len(sources) == 0 {
+				if def, ok := a.configDefault(key); ok {
+					fmt.Fprintf(out, "key:    %s\nvalue:  %s\nsource: default\n", key, def)
+					return nil
+				}
+				return errors.New("config key is not set: " + args[0])
+			}
+
+			value, _ := lookupPath(a.redactConfig(l.config), key)
+			if _, isMap := value.(map[string]any); isMap {
+				b, _ := json.Marshal(value)
+				value = string(b)
+			}
+			fmt.Fprintf(out, "key:    %s\nvalue:  %v\nsource: %s\n", key, value, sources[0])
+			for _, s := range sources[1:] {
+				fmt.Fprintf(out, "overrides: %s\n", s)
+			}
+			for _, s := range l.secrets {
+				if s == key || strings.HasPrefix(s, key+".") {
+					fmt.Fprintln(out, "secret: resolved from a secret reference")
+					break
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// describeSource adds the name of the environment variable setting key to the environment and dotenv sources.
+func (a *App) describeSource(l *loadedConfig, source, key string) string {
+	keys := l.config.AllKeys()
+	matches := func(name string) bool {
+		return envName(key) == name || envKey(name, keys) == key
+	}
+	switch source {
+	case "environment":
+		prefix := a.envPrefixName()
+		for _, kv := range os.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			if _, ok := lookupEnv(name); !ok {
+				continue
+			}
+			if prefix != "" {
+				var ok bool
+				if name, ok = strings.CutPrefix(name, prefix+"_"); !ok {
+					continue
+				}
+			}
+			if matches(name) {
+				return source + " " + strings.TrimPrefix(prefix+"_"+name, "_")
+			}
+		}
+	case "dotenv":
+		for name := range l.env {
+			if matches(name) {
+				return source + " " + name
+			}
+		}
+	}
+	return source
+}
+
+// configDefault returns the default tag of the field of a registered config type matching key.
+func (a *App) configDefault(key string) (string, bool) {
+	for _, t := range a.configTypes.types() {
+		parent, wildcard := strings.CutSuffix(t.key, ".*")
+		rest, ok := strings.CutPrefix(key, parent+".")
+		if !ok {
+			continue
+		}
+		if wildcard {
+			if _, rest, ok = strings.Cut(rest, "."); !ok {
+				continue
+			}
+		}
+		if def, ok := fieldDefault(t.typ, strings.Split(rest, ".")); ok {
+			return def, true
+		}
+	}
+	return "", false
+}
+
+// isConfigField reports whether key is a field of a registered config type, or an entry of one of its maps.
+func (a *App) isConfigField(key string) bool {
+	for _, t := range a.configTypes.types() {
+		parent, wildcard := strings.CutSuffix(t.key, ".*")
+		rest, ok := strings.CutPrefix(key, parent+".")
+		if parent == "" {
+			rest, ok = key, true
+		}
+		if !ok {
+			continue
+		}
+		if wildcard {
+			if _, rest, ok = strings.Cut(rest, "."); !ok {
+				continue
+			}
+		}
+		if hasField(t.typ, strings.Split(rest, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasField(t reflect.Type, path []string) bool {
+	st := structType(t)
+	if st == nil {
+		if t.Kind() == reflect.Map {
+			return len(path) == 1 || hasField(t.Elem(), path[1:])
+		}
+		return false
+	}
+	for _, f := range configFields(st) {
+		if f.name == path[0] {
+			return len(path) == 1 || hasField(f.field.Type, path[1:])
+		}
+	}
+	return false
+}
+
+func fieldDefault(t reflect.Type, path []string) (string, bool) {
+	st := structType(t)
+	if st == nil {
+		if t.Kind() == reflect.Map && len(path) > 1 {
+			return fieldDefault(t.Elem(), path[1:])
+		}
+		return "", false
+	}
+	for _, f := range configFields(st) {
+		if f.name != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return f.field.Tag.Lookup("default")
+		}
+		return fieldDefault(f.field.Type, path[1:])
+	}
+	return "", false
+}
+
+func lookupPath(m map[string]any, key string) (any, bool) {
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := toStringMap(m[p])
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	v, ok := m[parts[len(parts)-1]]
+	return v, ok
+}
+
+func writeFormatted(w io.Writer, format string, v any) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return errors.New("unknown output format: " + format)
+	}
+}
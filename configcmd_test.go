@@ -0,0 +1,87 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+)
+
+func TestConfigCommands(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	config := "telemetry:\n  service_name: from file\n  exporters:\n    otlp:\n      otlp_grpc_hots: localhost:4317\npgx:\n  example:\n    hosts: db:5432\n    pass: ${env:TEST_CONFIG_PASS}\n"
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_CONFIG_PASS", "password")
+	t.Setenv("TEST_TELEMETRY_SERVICE_NAME", "from env")
+
+	tests := []struct {
+		args     []string
+		exitCode int
+		stdout   []string
+		stderr   []string
+	}{
+		{
+			args:   []string{"config", "show"},
+			stdout: []string{"service_name: from env", "pass: '[REDACTED]'", "hosts: db:5432"},
+		},
+		{
+			args:   []string{"config", "show", "-o", "json"},
+			stdout: []string{`"service_name": "from env"`, `"pass": "[REDACTED]"`},
+		},
+		{
+			args:     []string{"config", "validate"},
+			exitCode: app.ExitCodeConfig,
+			stderr:   []string{"config is invalid:", "  telemetry.exporters.otlp.otlp_grpc_hots: unknown key"},
+		},
+		{
+			args:   []string{"config", "explain", "telemetry.service_name"},
+			stdout: []string{"value:  from env", "source: environment TEST_TELEMETRY_SERVICE_NAME", "overrides: config file " + configFile},
+		},
+		{
+			args:   []string{"config", "explain", "pgx.example.pass"},
+			stdout: []string{"value:  [REDACTED]", "source: config file", "secret: resolved from a secret reference"},
+		},
+		{
+			args:   []string{"config", "explain", "consul.wait_time"},
+			stdout: []string{"value:  5m", "source: default"},
+		},
+		{
+			args:     []string{"config", "explain", "unknown"},
+			exitCode: app.ExitCodeFailure,
+			stderr:   []string{"config key is not set: unknown"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			a := app.New(
+				app.WithRootCommand(&cobra.Command{Use: "test"}),
+				app.WithConfigFile(configFile),
+				app.WithEnvFiles(),
+				app.WithArgs(tt.args...),
+				app.WithOutput(&stdout, &stderr),
+			)
+			err := a.Execute(context.Background())
+			if code := app.ExitCode(err); code != tt.exitCode {
+				t.Fatalf("expect exit code %d, got %d: %v", tt.exitCode, code, err)
+			}
+			for _, s := range tt.stdout {
+				if !strings.Contains(stdout.String(), s) {
+					t.Errorf("expect %q in stdout, got:\n%s", s, stdout.String())
+				}
+			}
+			for _, s := range tt.stderr {
+				if !strings.Contains(stderr.String(), s) {
+					t.Errorf("expect %q in stderr, got:\n%s", s, stderr.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// profileEnv selects the config profile when the --profile flag is not set.
+const profileEnv = "APP_PROFILE"
+
+// includeKey lists the files included by a config file.
+const includeKey = "include"
+
+// configProfile returns the profile set by the --profile flag or the APP_PROFILE environment variable.
+func (a *App) configProfile() string {
+	if a.configFlags != nil && a.configFlags.profile != "" {
+		return a.configFlags.profile
+	}
+	return os.Getenv(profileEnv)
+}
+
+// configFileNames returns the config files set by the --config flags, or by SetConfigFiles.
+func (a *App) configFileNames() []string {
+	if a.configFlags != nil && len(a.configFlags.configFiles) > 0 {
+		return a.configFlags.configFiles
+	}
+	return a.configFiles
+}
+
+// readConfigFiles returns the settings of each config file, from the lowest to the highest precedence.
+// With a profile, the profile file of each config file is merged after it, eg: config.prod.yaml after config.yaml.
+func (a *App) readConfigFiles(flagFiles []string, profile string) ([]configLayer, error) {
+	if a.injectedConfig != nil && len(flagFiles) == 0 {
+		return []configLayer{{source: "injected config", settings: a.injectedConfig.AllSettings()}}, nil
+	}
+	files := a.configFileNames()
+	if len(files) == 0 {
+		slog.DebugContext(context.TODO(), "No config loaded")
+		return nil, nil
+	}
+
+	var layers []configLayer
+	profileFound := false
+	for _, file := range files {
+		fileLayers, err := readConfigFile(file, nil)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, fileLayers...)
+		if profile == "" {
+			continue
+		}
+
+		profileFile := profileFileName(file, profile)
+		if _, err := os.Stat(profileFile); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		profileFound = true
+		fileLayers, err = readConfigFile(profileFile, nil)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, fileLayers...)
+	}
+	if profile != "" && !profileFound {
+		return nil, fmt.Errorf("no config file found for profile %q, eg: %s", profile, profileFileName(files[0], profile))
+	}
+	return layers, nil
+}
+
+func profileFileName(file, profile string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + profile + ext
+}
+
+// readConfigFile returns the settings of the files included by file, followed by the settings of file,
+// so file overrides the files it includes. Included paths are relative to the including file, and may be glob patterns.
+func readConfigFile(file string, including []string) ([]configLayer, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range including {
+		if f == abs {
+			return nil, fmt.Errorf("config include cycle: %s -> %s", strings.Join(including, " -> "), abs)
+		}
+	}
+	including = append(including, abs)
+
+	cfg := viper.New()
+	cfg.SetConfigFile(file)
+	if err := cfg.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	settings := cfg.AllSettings()
+	includes, err := includedFiles(file, settings[includeKey])
+	if err != nil {
+		return nil, err
+	}
+	delete(settings, includeKey)
+
+	var layers []configLayer
+	for _, include := range includes {
+		included, err := readConfigFile(include, including)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, included...)
+	}
+	return append(layers, configLayer{source: "config file " + file, file: file, settings: settings}), nil
+}
+
+func includedFiles(file string, include any) ([]string, error) {
+	var patterns []string
+	switch v := include.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		patterns = []string{v}
+	case []any:
+		for _, p := range v {
+			patterns = append(patterns, fmt.Sprint(p))
+		}
+	default:
+		return nil, fmt.Errorf("%s: include must be a file or a list of files", file)
+	}
+
+	var files []string
+	for _, p := range patterns {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(file), p)
+		}
+		if !strings.ContainsAny(p, "*?[") {
+			files = append(files, p)
+			continue
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: include %s: %w", file, p, err)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -0,0 +1,124 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/yeka-go/app"
+)
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	base := write("base.yaml", "include:\n- telemetry.yaml\n- databases/*.yaml\ntelemetry:\n  service_name: base\n")
+	write("telemetry.yaml", "telemetry:\n  service_name: telemetry\n  exporters:\n    otlp:\n      otlp_grpc_host: localhost:4317\n      insecure: true\n")
+	write("databases/example.yaml", "pgx:\n  example:\n    hosts: localhost:5432\n    user: example\n")
+	write("databases/reporting.yaml", "pgx:\n  reporting:\n    hosts: localhost:5433\n")
+	write("base.prod.yaml", "telemetry:\n  exporters:\n    otlp:\n      otlp_grpc_host: collector:4317\npgx:\n  example:\n    hosts: db:5432\n")
+	local := write("local.yaml", "pgx:\n  reporting:\n    user: local\n")
+	cycle := write("cycle.yaml", "include: cycle-include.yaml\n")
+	write("cycle-include.yaml", "include: cycle.yaml\n")
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     string
+		expect  map[string]string
+		err     string
+		options []app.Option
+	}{
+		{
+			name: "includes",
+			args: []string{"-c", base},
+			expect: map[string]string{
+				"telemetry.service_name":                  "base",
+				"telemetry.exporters.otlp.otlp_grpc_host": "localhost:4317",
+				"pgx.example.hosts":                       "localhost:5432",
+				"pgx.reporting.hosts":                     "localhost:5433",
+				"include":                                 "",
+			},
+		},
+		{
+			name: "profile flag",
+			args: []string{"-c", base, "--profile", "prod"},
+			expect: map[string]string{
+				"telemetry.exporters.otlp.otlp_grpc_host": "collector:4317",
+				"telemetry.exporters.otlp.insecure":       "true",
+				"pgx.example.hosts":                       "db:5432",
+				"pgx.example.user":                        "example",
+			},
+		},
+		{
+			name:   "profile env",
+			env:    "prod",
+			expect: map[string]string{"pgx.example.hosts": "db:5432"},
+			options: []app.Option{
+				app.WithConfigFiles(base),
+			},
+		},
+		{
+			name: "multiple files",
+			args: []string{"-c", base, "-c", local},
+			expect: map[string]string{
+				"pgx.reporting.hosts": "localhost:5433",
+				"pgx.reporting.user":  "local",
+			},
+		},
+		{
+			name: "missing profile",
+			args: []string{"-c", base, "--profile", "staging"},
+			err:  `no config file found for profile "staging"`,
+		},
+		{
+			name: "include cycle",
+			args: []string{"-c", cycle},
+			err:  "config include cycle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_PROFILE", tt.env)
+			var cfg *viper.Viper
+			root := &cobra.Command{Use: "test", Run: func(cmd *cobra.Command, args []string) {
+				cfg = app.ConfigFromContext(cmd.Context())
+			}}
+			opts := append([]app.Option{
+				app.WithRootCommand(root),
+				app.WithEnvFiles(),
+				app.WithArgs(tt.args...),
+				app.WithOutput(io.Discard, io.Discard),
+			}, tt.options...)
+			err := app.New(opts...).Execute(context.Background())
+			if tt.err != "" {
+				if !errors.Is(err, app.ErrConfig) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expect config error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range tt.expect {
+				if got := cfg.GetString(key); got != value {
+					t.Errorf("expect %v to be %q, got %q", key, value, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const defaultConsulAddress = "127.0.0.1:8500"
+
+type consulConfig struct {
+	Address  string        `mapstructure:"address"`
+	Token    string        `mapstructure:"token"`
+	Prefix   string        `mapstructure:"prefix" validate:"required"`
+	Watch    bool          `mapstructure:"watch"`
+	WaitTime time.Duration `mapstructure:"wait_time" default:"5m" validate:"min=1s"`
+}
+
+// consulSource reads config from the keys under a prefix in Consul KV, eg: with prefix myapp/config,
+// the value of myapp/config/pgx/example/hosts is merged as pgx.example.hosts.
+type consulSource struct {
+	address  string
+	token    string
+	prefix   string
+	watch    bool
+	waitTime time.Duration
+	client   *http.Client
+
+	// index and kv of the last fetch
+	index uint64
+	kv    map[string]any
+}
+
+type consulKV struct {
+	Key   string
+	Value []byte
+}
+
+// newConsulSource returns nil when consul is not configured.
+func newConsulSource(cfg *viper.Viper) (*consulSource, error) {
+	if !cfg.IsSet("consul") {
+		return nil, nil
+	}
+	c, err := DecodeConfig[consulConfig](cfg, "consul")
+	if err != nil {
+		return nil, err
+	}
+	if c.Address == "" {
+		c.Address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if c.Address == "" {
+		c.Address = defaultConsulAddress
+	}
+	if !strings.Contains(c.Address, "://") {
+		c.Address = "http://" + c.Address
+	}
+	if c.Token == "" {
+		c.Token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	return &consulSource{
+		address:  strings.TrimSuffix(c.Address, "/"),
+		token:    c.Token,
+		prefix:   strings.Trim(c.Prefix, "/"),
+		watch:    c.Watch,
+		waitTime: c.WaitTime,
+		client:   &http.Client{},
+	}, nil
+}
+
+// fetch returns the config under the prefix, and the index to be used for the next blocking query.
+// With a non-zero index, fetch blocks until the keys change or the wait time elapses.
+func (c *consulSource) fetch(ctx context.Context, index uint64) (map[string]any, uint64, error) {
+	q := url.Values{"recurse": {"true"}}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", c.waitTime.String())
+	}
+	// the trailing slash keeps the sibling trees out, eg: myapp/config-staging for myapp/config
+	prefix := ""
+	if c.prefix != "" {
+		prefix = c.prefix + "/"
+	}
+	u := c.address + "/v1/kv/" + prefix + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: %w", err)
+	}
+	defer res.Body.Close()
+
+	newIndex, _ := strconv.ParseUint(res.Header.Get("X-Consul-Index"), 10, 64)
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return map[string]any{}, newIndex, nil
+	default:
+		return nil, 0, fmt.Errorf("consul: unexpected status %s", res.Status)
+	}
+
+	var kvs []consulKV
+	if err := json.NewDecoder(res.Body).Decode(&kvs); err != nil {
+		return nil, 0, fmt.Errorf("consul: %w", err)
+	}
+	cfg := make(map[string]any)
+	for _, kv := range kvs {
+		key, ok := strings.CutPrefix(kv.Key, prefix)
+		if !ok || key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+		setPath(cfg, strings.ToLower(strings.ReplaceAll(strings.Trim(key, "/"), "/", ".")), string(kv.Value))
+	}
+	return cfg, newIndex, nil
+}
+
+// watchConsul reloads the config whenever the keys under the prefix change.
+func (a *App) watchConsul(c *consulSource) WorkerFunc {
+	return func(ctx context.Context) error {
+		current, index := c.kv, c.index
+		for {
+			kv, newIndex, err := c.fetch(ctx, index)
+			if err != nil {
+				return err
+			}
+			if newIndex == 0 {
+				return errors.New("consul: missing X-Consul-Index header")
+			}
+			// the index must be reset when it goes backward, see consul blocking queries
+			if newIndex < index {
+				newIndex = 1
+			}
+			index = newIndex
+			if reflect.DeepEqual(kv, current) {
+				continue
+			}
+			current = kv
+			slog.InfoContext(ctx, "consul config changed", "prefix", c.prefix)
+			_ = a.reloadConfig(ctx)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+)
+
+// fakeConsul serves the KV endpoint of consul, including blocking queries.
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   uint64
+	kv      map[string]string
+	changed chan struct{}
+}
+
+func newFakeConsul(kv map[string]string) *fakeConsul {
+	return &fakeConsul{index: 1, kv: kv, changed: make(chan struct{})}
+}
+
+func (f *fakeConsul) set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.kv[key] = value
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Consul-Token") != "secret" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+
+	f.mu.Lock()
+	if index >= f.index {
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		f.mu.Lock()
+	}
+	defer f.mu.Unlock()
+
+	res := make([]map[string]any, 0)
+	for k, v := range f.kv {
+		if strings.HasPrefix(k, prefix) {
+			res = append(res, map[string]any{"Key": k, "Value": []byte(v)})
+		}
+	}
+	w.Header().Set("X-Consul-Index", fmt.Sprint(f.index))
+	if len(res) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func TestConsul(t *testing.T) {
+	consul := newFakeConsul(map[string]string{
+		"myapp/config/":                  "",
+		"myapp/config/pgx/example/hosts": "consul:5432",
+		"myapp/config/pgx/example/user":  "consul",
+		"myapp/config/greeting":          "hello",
+		"other/pgx/example/user":         "other",
+		"myapp/config-staging/greeting":  "staging",
+		"myapp/config-staging/debug":     "true",
+	})
+	srv := httptest.NewServer(consul)
+	defer srv.Close()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := fmt.Sprintf("consul:\n  prefix: myapp/config\n  watch: true\npgx:\n  example:\n    hosts: file:5432\n    dbname: file\n")
+	if err := os.WriteFile(configFile, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONSUL_HTTP_ADDR", srv.URL)
+	t.Setenv("CONSUL_HTTP_TOKEN", "secret")
+	t.Setenv("TEST_PGX_EXAMPLE_USER", "env")
+
+	root := &cobra.Command{
+		Use: "test",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := app.ConfigFromContext(cmd.Context())
+			expect := map[string]string{
+				"pgx.example.hosts":  "consul:5432",
+				"pgx.example.dbname": "file",
+				"pgx.example.user":   "env",
+				"greeting":           "hello",
+			}
+			for key, value := range expect {
+				if got := cfg.GetString(key); got != value {
+					t.Errorf("expect %v to be %q, got %q", key, value, got)
+				}
+			}
+			for _, key := range cfg.AllKeys() {
+				if strings.Contains(key, "staging") {
+					t.Errorf("expect the sibling tree to be left out, got %v", key)
+				}
+			}
+
+			consul.set("myapp/config/greeting", "hi")
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				if app.ConfigFromContext(cmd.Context()).GetString("greeting") == "hi" {
+					return nil
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return fmt.Errorf("expect config to be reloaded after consul change")
+		},
+	}
+
+	a := app.New(
+		app.WithRootCommand(root),
+		app.WithConfigFile(configFile),
+		app.WithEnvFiles(),
+		app.WithArgs(),
+		app.WithOutput(io.Discard, io.Discard),
+	)
+	if err := a.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsulTokenSecret(t *testing.T) {
+	srv := httptest.NewServer(newFakeConsul(map[string]string{"myapp/config/greeting": "hello"}))
+	defer srv.Close()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := fmt.Sprintf("consul:\n  address: %s\n  prefix: myapp/config\n  token: ${env:CONSUL_SECRET_TOKEN}\n", srv.URL)
+	if err := os.WriteFile(configFile, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONSUL_HTTP_TOKEN", "")
+	t.Setenv("CONSUL_SECRET_TOKEN", "secret")
+
+	var greeting string
+	a := app.New(
+		app.WithRootCommand(&cobra.Command{Use: "test", Run: func(cmd *cobra.Command, args []string) {
+			greeting = app.ConfigFromContext(cmd.Context()).GetString("greeting")
+		}}),
+		app.WithConfigFile(configFile),
+		app.WithEnvFiles(),
+		app.WithArgs(),
+		app.WithOutput(io.Discard, io.Discard),
+	)
+	if err := a.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if greeting != "hello" {
+		t.Errorf("expect greeting from consul with the resolved token, got %q", greeting)
+	}
+}
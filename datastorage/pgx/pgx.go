@@ -3,39 +3,90 @@ package pgx
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net/url"
+	"sync"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/yeka-go/app"
 )
 
-var instances = make(map[string]*pgx.Conn)
-
 type pgxConfig struct {
-	Hosts    string            `mapstructure:"hosts"`
+	Hosts    string            `mapstructure:"hosts" validate:"required"`
 	User     string            `mapstructure:"user"`
-	Password string            `mapstructure:"pass"`
+	Password app.Secret        `mapstructure:"pass"`
 	Database string            `mapstructure:"dbname"`
 	Options  map[string]string `mapstructure:"options"`
 }
 
-func Connect(cmdContext context.Context, connectionName string) (*pgx.Conn, error) {
-	conn, ok := instances[connectionName]
-	if ok {
-		return conn, nil
+func init() {
+	app.RegisterConfig[pgxConfig]("pgx.*")
+}
+
+// connection holds a second connection for the health checks, as a pgx.Conn is not safe for concurrent use,
+// so the checks served by the admin server do not race with the queries of the command.
+type connection struct {
+	config *pgx.ConnConfig
+	mu     sync.Mutex
+	conn   *pgx.Conn
+
+	healthMu sync.Mutex
+	health   *pgx.Conn
+}
+
+func (c *connection) Start(ctx context.Context) error {
+	conn, err := pgx.ConnectConfig(ctx, c.config)
+	if err != nil {
+		return err
+	}
+	// the health checks are not traced
+	healthConfig := c.config.Copy()
+	healthConfig.Tracer = nil
+	health, err := pgx.ConnectConfig(ctx, healthConfig)
+	if err != nil {
+		return errors.Join(err, conn.Close(ctx))
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn, c.health = conn, health
+	return nil
+}
+
+// started returns the connection, nil until the component is started.
+func (c *connection) started() *pgx.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
 
+func (c *connection) Stop(ctx context.Context) error {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+	return errors.Join(c.conn.Close(ctx), c.health.Close(ctx))
+}
+
+func (c *connection) Health(ctx context.Context) error {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+	return c.health.Ping(ctx)
+}
+
+func Connect(cmdContext context.Context, connectionName string) (*pgx.Conn, error) {
+	a := app.FromContext(cmdContext)
 	configKey := "pgx." + connectionName
+	if c, ok := a.LookupComponent(configKey); ok {
+		if conn := c.(*connection).started(); conn != nil {
+			return conn, nil
+		}
+	}
+
 	config := app.ConfigFromContext(cmdContext)
 	if config == nil || !config.IsSet(configKey) {
 		return nil, errors.New("config not found for " + configKey)
 	}
 
-	var cfg pgxConfig
-	err := config.UnmarshalKey(configKey, &cfg)
+	cfg, err := app.ConfigAs[pgxConfig](cmdContext, configKey)
 	if err != nil {
-		return nil, fmt.Errorf("config.UnmarshalKey: %w", err)
+		return nil, err
 	}
 
 	q := url.Values{}
@@ -46,20 +97,25 @@ func Connect(cmdContext context.Context, connectionName string) (*pgx.Conn, erro
 	dsn := url.URL{
 		Scheme:   "postgres",
 		Host:     cfg.Hosts,
-		User:     url.UserPassword(cfg.User, cfg.Password),
+		User:     url.UserPassword(cfg.User, string(cfg.Password)),
 		Path:     cfg.Database,
 		RawQuery: q.Encode(),
 	}
 
-	conf, _ := pgx.ParseConfig(dsn.String())
-	conf.Tracer = &tracer{dbname: conf.Database}
-
-	conn, err = pgx.ConnectConfig(context.Background(), conf)
+	conf, err := pgx.ParseConfig(dsn.String())
 	if err != nil {
 		return nil, err
 	}
+	conf.Tracer = newTracer(conf.Database)
 
-	instances[connectionName] = conn
-	app.OnShutdown(conn.Close)
+	// a concurrent call may have registered the connection meanwhile
+	c, _, err := a.LoadOrRegister(configKey, &connection{config: conf}, app.TelemetryComponent)
+	if err != nil {
+		return nil, err
+	}
+	conn := c.(*connection).started()
+	if conn == nil {
+		return nil, errors.New("application is not running, unable to connect " + configKey)
+	}
 	return conn, nil
 }
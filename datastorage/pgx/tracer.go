@@ -3,19 +3,35 @@ package pgx
 import (
 	"context"
 	"net"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type tracer struct {
-	dbname string
+	dbname   string
+	duration metric.Float64Histogram
+}
+
+type queryStartKey struct{}
+
+func newTracer(dbname string) *tracer {
+	// the global meter forwards to the provider set by the telemetry component, even once set afterwards
+	duration, _ := otel.Meter("pgx").Float64Histogram(
+		"db.client.operation.duration",
+		metric.WithDescription("Duration of the queries"),
+		metric.WithUnit("s"),
+	)
+	return &tracer{dbname: dbname, duration: duration}
 }
 
 func (t *tracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx = context.WithValue(ctx, queryStartKey{}, time.Now())
 	ctx, span := otel.Tracer("pgx").Start(ctx, "query")
 	host, port, _ := net.SplitHostPort(conn.PgConn().Conn().RemoteAddr().String())
 	span.SetAttributes(
@@ -32,20 +48,32 @@ func (t *tracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.Tra
 	span := trace.SpanFromContext(ctx)
 	defer span.End()
 
+	var operation string
 	if data.CommandTag.Insert() {
-		span.SetName("INSERT")
+		operation = "INSERT"
 	} else if data.CommandTag.Delete() {
-		span.SetName("DELETE")
+		operation = "DELETE"
 	} else if data.CommandTag.Select() {
-		span.SetName("SELECT")
+		operation = "SELECT"
 	} else if data.CommandTag.Update() {
-		span.SetName("UPDATE")
+		operation = "UPDATE"
 	} else {
-		span.SetName(data.CommandTag.String())
+		operation = data.CommandTag.String()
 	}
+	span.SetName(operation)
 
 	span.RecordError(data.Err)
+	status := "ok"
 	if data.Err != nil {
 		span.SetStatus(codes.Error, data.Err.Error())
+		status = "error"
+	}
+
+	if start, ok := ctx.Value(queryStartKey{}).(time.Time); ok {
+		t.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+			attribute.String("db.namespace", t.dbname),
+			attribute.String("db.operation.name", operation),
+			attribute.String("status", status),
+		))
 	}
 }
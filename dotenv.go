@@ -0,0 +1,110 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"sync"
+
+	"github.com/subosito/gotenv"
+)
+
+const defaultEnvFile = ".env"
+
+func SetEnvFiles(files ...string) {
+	defaultApp.SetEnvFiles(files...)
+}
+
+// SetEnvFiles sets the dotenv files loaded before the config, replacing the default .env file.
+// Unlike the default, these files must exist.
+func (a *App) SetEnvFiles(files ...string) {
+	a.envFiles = append([]string{}, files...)
+}
+
+func WithEnvFiles(files ...string) Option {
+	return func(a *App) {
+		a.SetEnvFiles(files...)
+	}
+}
+
+// dotenvExported holds the variables exported to the process environment from the dotenv files, with their value,
+// so they are not taken for real environment variables when the config is loaded again.
+var dotenvExported = struct {
+	sync.Mutex
+	vars map[string]string
+}{vars: make(map[string]string)}
+
+// lookupEnv returns the value of an environment variable that was not exported from the dotenv files.
+func lookupEnv(name string) (string, bool) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", false
+	}
+	dotenvExported.Lock()
+	defer dotenvExported.Unlock()
+	if exported, ok := dotenvExported.vars[name]; ok && exported == v {
+		return "", false
+	}
+	return v, true
+}
+
+// loadEnvFiles reads the dotenv files, later files overriding earlier ones.
+// Variables are exported to the process environment unless already set there,
+// and the returned values hold the process environment value for those.
+// The variables exported by a previous load are updated, or unset once removed from the files.
+func (a *App) loadEnvFiles(flagFiles []string) (map[string]string, error) {
+	env := make(map[string]string)
+	load := func(file string, required bool) error {
+		vars, err := gotenv.Read(file)
+		if errors.Is(err, fs.ErrNotExist) && !required {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("env file %s: %w", file, err)
+		}
+		maps.Copy(env, vars)
+		return nil
+	}
+
+	if a.envFiles == nil {
+		if err := load(defaultEnvFile, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range append(a.envFiles, flagFiles...) {
+		if err := load(file, true); err != nil {
+			return nil, err
+		}
+	}
+
+	dotenvExported.Lock()
+	defer dotenvExported.Unlock()
+	for name, exported := range dotenvExported.vars {
+		v, ok := os.LookupEnv(name)
+		if !ok || v != exported {
+			// changed outside of the dotenv files, it is a real environment variable now
+			delete(dotenvExported.vars, name)
+			continue
+		}
+		if _, ok := env[name]; !ok {
+			if err := os.Unsetenv(name); err != nil {
+				return nil, err
+			}
+			delete(dotenvExported.vars, name)
+		}
+	}
+	for name, value := range env {
+		_, exported := dotenvExported.vars[name]
+		if v, ok := os.LookupEnv(name); ok && !exported {
+			env[name] = v
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return nil, err
+		}
+		dotenvExported.vars[name] = value
+	}
+	return env, nil
+}
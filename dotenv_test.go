@@ -0,0 +1,167 @@
+package app_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+)
+
+func TestEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	configFile := write("config.yaml", "telemetry:\n  service_name: from file\npgx:\n  example:\n    user: file\n")
+	envFile := write("app.env", "PGX_EXAMPLE_HOSTS=from-dotenv:5432\nPGX_EXAMPLE_USER=dotenv\nTELEMETRY_SERVICE_NAME=dotenv\nAPPTEST_DOTENV_REAL=dotenv\n")
+	flagFile := write("flag.env", "PGX__EXAMPLE__OPTIONS__APPLICATION_NAME=from-flag\nPGX_EXAMPLE_HOSTS=from-flag:5432\n")
+	t.Setenv("APPTEST_DOTENV_REAL", "real")
+
+	var cfg map[string]string
+	root := &cobra.Command{
+		Use: "test",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := app.ConfigFromContext(cmd.Context())
+			cfg = map[string]string{
+				"pgx.example.hosts":                    c.GetString("pgx.example.hosts"),
+				"pgx.example.user":                     c.GetString("pgx.example.user"),
+				"pgx.example.options.application_name": c.GetString("pgx.example.options.application_name"),
+				"telemetry.service_name":               c.GetString("telemetry.service_name"),
+				"apptest.dotenv.real":                  c.GetString("apptest.dotenv.real"),
+				"env PGX_EXAMPLE_HOSTS":                os.Getenv("PGX_EXAMPLE_HOSTS"),
+				"env APPTEST_DOTENV_REAL":              os.Getenv("APPTEST_DOTENV_REAL"),
+			}
+		},
+	}
+	t.Cleanup(func() {
+		for _, name := range []string{"PGX_EXAMPLE_HOSTS", "PGX_EXAMPLE_USER", "TELEMETRY_SERVICE_NAME", "PGX__EXAMPLE__OPTIONS__APPLICATION_NAME"} {
+			os.Unsetenv(name)
+		}
+	})
+
+	a := app.New(
+		app.WithRootCommand(root),
+		app.WithConfigFile(configFile),
+		app.WithEnvFiles(envFile),
+		app.WithArgs("--env-file", flagFile),
+		app.WithOutput(io.Discard, io.Discard),
+	)
+	if err := a.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]string{
+		"pgx.example.hosts":                    "from-flag:5432",
+		"pgx.example.user":                     "file",
+		"pgx.example.options.application_name": "from-flag",
+		"telemetry.service_name":               "from file",
+		"apptest.dotenv.real":                  "real",
+		"env PGX_EXAMPLE_HOSTS":                "from-flag:5432",
+		"env APPTEST_DOTENV_REAL":              "real",
+	}
+	for k, v := range expect {
+		if cfg[k] != v {
+			t.Errorf("expect %v to be %q, got %q", k, v, cfg[k])
+		}
+	}
+
+	a = app.New(
+		app.WithRootCommand(&cobra.Command{Use: "test", Run: func(cmd *cobra.Command, args []string) {}}),
+		app.WithEnvFiles(filepath.Join(dir, "missing.env")),
+		app.WithArgs(),
+		app.WithOutput(io.Discard, io.Discard),
+	)
+	if code := app.ExitCode(a.Execute(context.Background())); code != app.ExitCodeConfig {
+		t.Errorf("expect missing env file to be a config error, got %v", code)
+	}
+}
+
+func TestEnvFilesLoadedAgain(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	envFile := filepath.Join(dir, "app.env")
+	write := func(file, content string) {
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(configFile, "greeting: from file\n")
+	t.Cleanup(func() {
+		for _, name := range []string{"APPTEST_GREETING", "APPTEST_DOTENV_NAME", "APPTEST_DOTENV_REMOVED"} {
+			os.Unsetenv(name)
+		}
+	})
+
+	execute := func() map[string]string {
+		var cfg map[string]string
+		root := &cobra.Command{
+			Use: "apptest",
+			Run: func(cmd *cobra.Command, args []string) {
+				c := app.ConfigFromContext(cmd.Context())
+				removed, ok := os.LookupEnv("APPTEST_DOTENV_REMOVED")
+				if !ok {
+					removed = "unset"
+				}
+				cfg = map[string]string{
+					"greeting":                   c.GetString("greeting"),
+					"apptest.dotenv.name":        c.GetString("apptest.dotenv.name"),
+					"env APPTEST_DOTENV_NAME":    os.Getenv("APPTEST_DOTENV_NAME"),
+					"env APPTEST_DOTENV_REMOVED": removed,
+				}
+			},
+		}
+		err := app.New(
+			app.WithRootCommand(root),
+			app.WithConfigFile(configFile),
+			app.WithEnvFiles(envFile),
+			app.WithEnvPrefix("APPTEST"),
+			app.WithArgs(),
+			app.WithOutput(io.Discard, io.Discard),
+		).Execute(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	testData := []struct {
+		env    string
+		expect map[string]string
+	}{
+		{
+			env: "APPTEST_GREETING=from dotenv\nAPPTEST_DOTENV_NAME=first\nAPPTEST_DOTENV_REMOVED=set\n",
+			expect: map[string]string{
+				"greeting":                   "from file",
+				"apptest.dotenv.name":        "first",
+				"env APPTEST_DOTENV_NAME":    "first",
+				"env APPTEST_DOTENV_REMOVED": "set",
+			},
+		},
+		{
+			env: "APPTEST_GREETING=from dotenv\nAPPTEST_DOTENV_NAME=second\n",
+			expect: map[string]string{
+				"greeting":                   "from file",
+				"apptest.dotenv.name":        "second",
+				"env APPTEST_DOTENV_NAME":    "second",
+				"env APPTEST_DOTENV_REMOVED": "unset",
+			},
+		},
+	}
+	for i, v := range testData {
+		write(envFile, v.env)
+		cfg := execute()
+		for k, expect := range v.expect {
+			if cfg[k] != expect {
+				t.Errorf("scenario #%v, expect %v to be %q, got %q", i+1, k, expect, cfg[k])
+			}
+		}
+	}
+}
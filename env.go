@@ -0,0 +1,112 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+func SetEnvPrefix(prefix string) {
+	defaultApp.SetEnvPrefix(prefix)
+}
+
+// SetEnvPrefix sets the prefix of environment variables overriding config keys, eg: with prefix MYAPP,
+// MYAPP_PGX_EXAMPLE_PASS overrides pgx.example.pass, and every variable with the prefix is added to the config.
+// The default prefix is the application name, its variables only set the keys present in the config
+// or the fields of the registered config types, so unrelated variables sharing the prefix are left out.
+// With an empty prefix, only keys already present in the config can be overridden.
+func (a *App) SetEnvPrefix(prefix string) {
+	a.envPrefix = &prefix
+}
+
+func WithEnvPrefix(prefix string) Option {
+	return func(a *App) {
+		a.SetEnvPrefix(prefix)
+	}
+}
+
+func (a *App) envPrefixName() string {
+	if a.envPrefix != nil {
+		return *a.envPrefix
+	}
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, a.appInfo().Name)
+}
+
+// envOverrides returns the config values set by environment variables, as a nested map.
+// With the default prefix, only the variables mapping to one of keys or to a registered config field are read.
+func (a *App) envOverrides(keys []string) map[string]any {
+	prefix, strict := a.envPrefixName(), a.envPrefix == nil
+	res := make(map[string]any)
+	if prefix == "" {
+		for _, key := range keys {
+			if value, ok := lookupEnv(envName(key)); ok {
+				setPath(res, key, value)
+			}
+		}
+		return res
+	}
+
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key] = true
+	}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if _, ok := lookupEnv(name); !ok || name == profileEnv {
+			// exported from the dotenv files, it belongs to the dotenv source,
+			// and the profile is read before the config
+			continue
+		}
+		name, ok := strings.CutPrefix(name, prefix+"_")
+		if !ok || name == "" {
+			continue
+		}
+		key := envKey(name, keys)
+		if strict && !known[key] && !a.isConfigField(key) {
+			continue
+		}
+		setPath(res, key, value)
+	}
+	return res
+}
+
+// setPath sets value in m at the dot separated key, creating nested maps as needed.
+func setPath(m map[string]any, key string, value any) {
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[p] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// envKey maps an environment variable name to a config key, eg: PGX_EXAMPLE_HOSTS to pgx.example.hosts.
+// A name matching one of keys maps to that key, so TELEMETRY_SERVICE_NAME maps to telemetry.service_name
+// when it exists in the config file. Otherwise a double underscore separates levels while keeping
+// single underscores, eg: PGX__REPORTING__OPTIONS__APPLICATION_NAME to pgx.reporting.options.application_name.
+func envKey(name string, keys []string) string {
+	for _, k := range keys {
+		if envName(k) == name {
+			return k
+		}
+	}
+	key := strings.ToLower(name)
+	if strings.Contains(key, "__") {
+		return strings.ReplaceAll(key, "__", ".")
+	}
+	return strings.ReplaceAll(key, "_", ".")
+}
+
+// envName maps a config key to an environment variable name, eg: pgx.example.hosts to PGX_EXAMPLE_HOSTS.
+func envName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
@@ -0,0 +1,122 @@
+package app_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/yeka-go/app"
+)
+
+func TestEnvOverrides(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configFile, []byte("telemetry:\n  service_name: from file\n  exporters:\n    collector:\n      otlp_grpc_host: localhost:4317\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(filepath.Dir(configFile), "config.staging.yaml"), []byte("{}\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("MY_APP_TELEMETRY_EXPORTERS_COLLECTOR_OTLP_GRPC_HOST", "collector:4317")
+	t.Setenv("MY_APP_PGX_REPORTING_HOSTS", "db:5432")
+	t.Setenv("MY_APP_PGX_REPORTING_USER", "report")
+	t.Setenv("MY_APP_PGX__REPORTING__OPTIONS__APPLICATION_NAME", "reporting")
+	t.Setenv("TELEMETRY_SERVICE_NAME", "without prefix")
+	t.Setenv("APP_PROFILE", "staging")
+	t.Setenv("APP_TELEMETRY_SERVICE_NAME", "from app")
+
+	testData := []struct {
+		Name   string
+		Option app.Option
+		Expect map[string]string
+	}{
+		{
+			Name:   "default prefix from app name only sets known keys",
+			Option: app.WithInfo(app.Info{Name: "my-app"}),
+			Expect: map[string]string{
+				"telemetry.service_name":                       "from file",
+				"telemetry.exporters.collector.otlp_grpc_host": "collector:4317",
+				"telemetry.exporters.collector.otlp.grpc.host": "",
+				"pgx.reporting.hosts":                          "",
+				"pgx.reporting.user":                           "",
+			},
+		},
+		{
+			Name:   "explicit prefix sets any key",
+			Option: app.WithEnvPrefix("MY_APP"),
+			Expect: map[string]string{
+				"telemetry.exporters.collector.otlp_grpc_host": "collector:4317",
+				"pgx.reporting.hosts":                          "db:5432",
+				"pgx.reporting.user":                           "report",
+				"pgx.reporting.options.application_name":       "reporting",
+				"pgx.reporting.options.application.name":       "",
+			},
+		},
+		{
+			Name:   "profile variable is not a config key",
+			Option: app.WithEnvPrefix("APP"),
+			Expect: map[string]string{
+				"telemetry.service_name": "from app",
+				"profile":                "",
+			},
+		},
+		{
+			Name:   "empty prefix only overrides existing keys",
+			Option: app.WithEnvPrefix(""),
+			Expect: map[string]string{
+				"telemetry.service_name": "without prefix",
+				"pgx.reporting.hosts":    "",
+			},
+		},
+	}
+
+	for _, v := range testData {
+		var cfg *viper.Viper
+		a := app.New(
+			app.WithRootCommand(&cobra.Command{Use: "test", Run: func(cmd *cobra.Command, args []string) {
+				cfg = app.ConfigFromContext(cmd.Context())
+			}}),
+			app.WithConfigFile(configFile),
+			app.WithEnvFiles(),
+			app.WithArgs(),
+			app.WithOutput(io.Discard, io.Discard),
+			v.Option,
+		)
+		if err := a.Execute(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range v.Expect {
+			if got := cfg.GetString(key); got != value {
+				t.Errorf("\nscenario %q, expect %v to be %q, got %q", v.Name, key, value, got)
+			}
+		}
+	}
+}
+
+func TestEnvOnlyConnection(t *testing.T) {
+	t.Setenv("TEST_PGX_REPORTING_HOSTS", "db:5432")
+	t.Setenv("TEST_PGX_REPORTING_DBNAME", "reports")
+
+	var conn map[string]string
+	a := app.New(
+		app.WithRootCommand(&cobra.Command{Use: "test", RunE: func(cmd *cobra.Command, args []string) error {
+			return app.ConfigFromContext(cmd.Context()).UnmarshalKey("pgx.reporting", &conn)
+		}}),
+		app.WithEnvFiles(),
+		app.WithEnvPrefix("TEST"),
+		app.WithArgs(),
+		app.WithOutput(io.Discard, io.Discard),
+	)
+	if err := a.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if conn["hosts"] != "db:5432" || conn["dbname"] != "reports" {
+		t.Errorf("expect connection defined through env, got %v", conn)
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes used by Run, loosely following sysexits.h.
+const (
+	ExitCodeOK       = 0
+	ExitCodeFailure  = 1
+	ExitCodePanic    = 70
+	ExitCodeShutdown = 75
+	ExitCodeConfig   = 78
+)
+
+var (
+	ErrPanic    = errors.New("panic")
+	ErrConfig   = errors.New("config error")
+	ErrShutdown = errors.New("shutdown error")
+)
+
+// ExitError makes Run exit with Code. It can be returned by a command, directly or wrapped.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the process exit code for err, as used by Run.
+func ExitCode(err error) int {
+	var exitErr ExitError
+	var exitErrPtr *ExitError
+	switch {
+	case err == nil:
+		return ExitCodeOK
+	case errors.As(err, &exitErr):
+		return exitErr.Code
+	case errors.As(err, &exitErrPtr):
+		return exitErrPtr.Code
+	case errors.Is(err, ErrPanic):
+		return ExitCodePanic
+	case errors.Is(err, ErrConfig):
+		return ExitCodeConfig
+	case errors.Is(err, ErrShutdown):
+		return ExitCodeShutdown
+	default:
+		return ExitCodeFailure
+	}
+}
@@ -0,0 +1,85 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+)
+
+func TestExitCode(t *testing.T) {
+	testData := []struct {
+		Err    error
+		Expect int
+	}{
+		{nil, app.ExitCodeOK},
+		{errors.New("failure"), app.ExitCodeFailure},
+		{app.ExitError{Code: 3}, 3},
+		{fmt.Errorf("wrapped: %w", app.ExitError{Code: 4, Err: app.ErrConfig}), 4},
+		{&app.ExitError{Code: 5}, 5},
+		{fmt.Errorf("%w: boom", app.ErrPanic), app.ExitCodePanic},
+		{fmt.Errorf("%w: invalid yaml", app.ErrConfig), app.ExitCodeConfig},
+		{fmt.Errorf("%w: db: timeout", app.ErrShutdown), app.ExitCodeShutdown},
+	}
+	for i, v := range testData {
+		if code := app.ExitCode(v.Err); code != v.Expect {
+			t.Errorf("\nscenario #%v, expect %v, got %v", i+1, v.Expect, code)
+		}
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	testData := []struct {
+		Name     string
+		Args     []string
+		Run      func(cmd *cobra.Command, args []string) error
+		Shutdown app.ShutdownFunc
+		Expect   int
+	}{
+		{
+			Name:   "success",
+			Run:    func(cmd *cobra.Command, args []string) error { return nil },
+			Expect: app.ExitCodeOK,
+		},
+		{
+			Name:   "panic",
+			Run:    func(cmd *cobra.Command, args []string) error { panic("boom") },
+			Expect: app.ExitCodePanic,
+		},
+		{
+			Name:   "missing config file",
+			Args:   []string{"--config", "missing.yaml"},
+			Run:    func(cmd *cobra.Command, args []string) error { return nil },
+			Expect: app.ExitCodeConfig,
+		},
+		{
+			Name:     "shutdown failure",
+			Run:      func(cmd *cobra.Command, args []string) error { return nil },
+			Shutdown: func(ctx context.Context) error { return errors.New("failure") },
+			Expect:   app.ExitCodeShutdown,
+		},
+		{
+			Name:     "command failure wins over shutdown failure",
+			Run:      func(cmd *cobra.Command, args []string) error { return app.ExitError{Code: 9} },
+			Shutdown: func(ctx context.Context) error { return errors.New("failure") },
+			Expect:   9,
+		},
+	}
+	for _, v := range testData {
+		a := app.New(
+			app.WithRootCommand(&cobra.Command{Use: "test", RunE: v.Run}),
+			app.WithArgs(v.Args...),
+			app.WithOutput(io.Discard, io.Discard),
+		)
+		if v.Shutdown != nil {
+			a.OnShutdown(v.Shutdown)
+		}
+		if code := app.ExitCode(a.Execute(context.Background())); code != v.Expect {
+			t.Errorf("\nscenario %q, expect %v, got %v", v.Name, v.Expect, code)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	logsdk "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	exporterOTLP   = "otlp"
+	exporterStdout = "stdout"
+	exporterFile   = "file"
+	// exporterPrometheus serves the metrics to be scraped instead of pushing them
+	exporterPrometheus = "prometheus"
+
+	protocolGRPC = "grpc"
+	protocolHTTP = "http/protobuf"
+)
+
+type exporterConfig struct {
+	// Type of the exporter: otlp, stdout, file to write JSON lines to Path,
+	// or prometheus to serve the metrics on Addr, or on the admin server when Addr is empty
+	Type string `mapstructure:"type" default:"otlp" validate:"oneof=otlp stdout file prometheus"`
+	Path string `mapstructure:"path"`
+	Addr string `mapstructure:"addr" validate:"hostport"`
+	// Pretty prints indented JSON with the stdout and file exporters, by default when stdout is a terminal
+	Pretty *bool `mapstructure:"pretty"`
+
+	// Protocol of the OTLP exporter, grpc or http/protobuf
+	Protocol string `mapstructure:"protocol" default:"grpc" validate:"oneof=grpc http/protobuf"`
+	// Endpoint is the URL of the collector, eg: http://localhost:4318 for http/protobuf.
+	// The http/protobuf exporters append the signal path, eg: /v1/traces, unless the URL has a path.
+	Endpoint string `mapstructure:"endpoint" validate:"url"`
+	// OtelGRPCAddr is the host:port of the collector, Endpoint takes precedence.
+	OtelGRPCAddr string        `mapstructure:"otlp_grpc_host" validate:"hostport"`
+	Paths        exporterPaths `mapstructure:"paths"`
+	// Headers are sent with every request, they are secrets as they usually hold credentials
+	Headers     map[string]Secret `mapstructure:"headers"`
+	Insecure    bool              `mapstructure:"insecure"`
+	Compression string            `mapstructure:"compression" default:"gzip" validate:"oneof=gzip none"`
+	Timeout     time.Duration     `mapstructure:"timeout" default:"10s" validate:"min=1ms"`
+	Retry       retryConfig       `mapstructure:"retry"`
+}
+
+func (e exporterConfig) headers() map[string]string {
+	headers := make(map[string]string, len(e.Headers))
+	for k, v := range e.Headers {
+		headers[k] = string(v)
+	}
+	return headers
+}
+
+// exporterPaths overrides the URL path of each signal for the http/protobuf protocol.
+type exporterPaths struct {
+	Traces  string `mapstructure:"traces"`
+	Metrics string `mapstructure:"metrics"`
+	Logs    string `mapstructure:"logs"`
+}
+
+type retryConfig struct {
+	Enabled         bool          `mapstructure:"enabled" default:"true"`
+	InitialInterval time.Duration `mapstructure:"initial_interval" default:"5s"`
+	MaxInterval     time.Duration `mapstructure:"max_interval" default:"30s"`
+	MaxElapsedTime  time.Duration `mapstructure:"max_elapsed_time" default:"1m"`
+}
+
+func (e exporterConfig) endpoint() string {
+	if e.Endpoint != "" || e.OtelGRPCAddr == "" {
+		return e.Endpoint
+	}
+	scheme := "https://"
+	if e.Insecure {
+		scheme = "http://"
+	}
+	return scheme + e.OtelGRPCAddr
+}
+
+// open returns the destination of the stdout and file exporters.
+func (e exporterConfig) open() (io.WriteCloser, error) {
+	if e.Type != exporterFile {
+		return nopCloser{os.Stdout}, nil
+	}
+	if e.Path == "" {
+		return nil, fmt.Errorf("%w: the file exporter requires a path", ErrConfig)
+	}
+	return os.OpenFile(e.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+}
+
+func (e exporterConfig) pretty() bool {
+	if e.Pretty != nil {
+		return *e.Pretty
+	}
+	return e.Type == exporterStdout && isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// the exporters writing to a file close it on shutdown
+
+type closingSpanExporter struct {
+	trace.SpanExporter
+	w io.Closer
+}
+
+func (e closingSpanExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.w.Close())
+}
+
+type closingMetricExporter struct {
+	metric.Exporter
+	w io.Closer
+}
+
+func (e closingMetricExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.w.Close())
+}
+
+type closingLogExporter struct {
+	logsdk.Exporter
+	w io.Closer
+}
+
+func (e closingLogExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.w.Close())
+}
+
+func newSpanExporter(ctx context.Context, e exporterConfig) (trace.SpanExporter, error) {
+	if e.Type != exporterOTLP {
+		w, err := e.open()
+		if err != nil {
+			return nil, err
+		}
+		opts := []stdouttrace.Option{stdouttrace.WithWriter(w)}
+		if e.pretty() {
+			opts = append(opts, stdouttrace.WithPrettyPrint())
+		}
+		exp, err := stdouttrace.New(opts...)
+		if err != nil {
+			return nil, errors.Join(err, w.Close())
+		}
+		return closingSpanExporter{exp, w}, nil
+	}
+	if e.Protocol == protocolHTTP {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithTimeout(e.Timeout),
+			otlptracehttp.WithRetry(otlptracehttp.RetryConfig(e.Retry)),
+		}
+		if e.endpoint() != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(e.endpoint()))
+		}
+		if e.Paths.Traces != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(e.Paths.Traces))
+		}
+		if e.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(e.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(e.headers()))
+		}
+		if e.Compression == "gzip" {
+			opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithTimeout(e.Timeout),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(e.Retry)),
+	}
+	if e.endpoint() != "" {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(e.endpoint()))
+	}
+	if e.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	if len(e.Headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(e.headers()))
+	}
+	if e.Compression == "gzip" {
+		opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+func newMetricExporter(ctx context.Context, e exporterConfig) (metric.Exporter, error) {
+	if e.Type != exporterOTLP {
+		w, err := e.open()
+		if err != nil {
+			return nil, err
+		}
+		opts := []stdoutmetric.Option{stdoutmetric.WithWriter(w)}
+		if e.pretty() {
+			opts = append(opts, stdoutmetric.WithPrettyPrint())
+		}
+		exp, err := stdoutmetric.New(opts...)
+		if err != nil {
+			return nil, errors.Join(err, w.Close())
+		}
+		return closingMetricExporter{exp, w}, nil
+	}
+	if e.Protocol == protocolHTTP {
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithTimeout(e.Timeout),
+			otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(e.Retry)),
+		}
+		if e.endpoint() != "" {
+			opts = append(opts, otlpmetrichttp.WithEndpointURL(e.endpoint()))
+		}
+		if e.Paths.Metrics != "" {
+			opts = append(opts, otlpmetrichttp.WithURLPath(e.Paths.Metrics))
+		}
+		if e.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		if len(e.Headers) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(e.headers()))
+		}
+		if e.Compression == "gzip" {
+			opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithTimeout(e.Timeout),
+		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(e.Retry)),
+	}
+	if e.endpoint() != "" {
+		opts = append(opts, otlpmetricgrpc.WithEndpointURL(e.endpoint()))
+	}
+	if e.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	if len(e.Headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(e.headers()))
+	}
+	if e.Compression == "gzip" {
+		opts = append(opts, otlpmetricgrpc.WithCompressor("gzip"))
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+func newLogExporter(ctx context.Context, e exporterConfig) (logsdk.Exporter, error) {
+	if e.Type != exporterOTLP {
+		w, err := e.open()
+		if err != nil {
+			return nil, err
+		}
+		opts := []stdoutlog.Option{stdoutlog.WithWriter(w)}
+		if e.pretty() {
+			opts = append(opts, stdoutlog.WithPrettyPrint())
+		}
+		exp, err := stdoutlog.New(opts...)
+		if err != nil {
+			return nil, errors.Join(err, w.Close())
+		}
+		return closingLogExporter{exp, w}, nil
+	}
+	if e.Protocol == protocolHTTP {
+		opts := []otlploghttp.Option{
+			otlploghttp.WithTimeout(e.Timeout),
+			otlploghttp.WithRetry(otlploghttp.RetryConfig(e.Retry)),
+		}
+		if e.endpoint() != "" {
+			opts = append(opts, otlploghttp.WithEndpointURL(e.endpoint()))
+		}
+		if e.Paths.Logs != "" {
+			opts = append(opts, otlploghttp.WithURLPath(e.Paths.Logs))
+		}
+		if e.Insecure {
+			opts = append(opts, otlploghttp.WithInsecure())
+		}
+		if len(e.Headers) > 0 {
+			opts = append(opts, otlploghttp.WithHeaders(e.headers()))
+		}
+		if e.Compression == "gzip" {
+			opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+		}
+		return otlploghttp.New(ctx, opts...)
+	}
+
+	opts := []otlploggrpc.Option{
+		otlploggrpc.WithTimeout(e.Timeout),
+		otlploggrpc.WithRetry(otlploggrpc.RetryConfig(e.Retry)),
+	}
+	if e.endpoint() != "" {
+		opts = append(opts, otlploggrpc.WithEndpointURL(e.endpoint()))
+	}
+	if e.Insecure {
+		opts = append(opts, otlploggrpc.WithInsecure())
+	}
+	if len(e.Headers) > 0 {
+		opts = append(opts, otlploggrpc.WithHeaders(e.headers()))
+	}
+	if e.Compression == "gzip" {
+		opts = append(opts, otlploggrpc.WithCompressor("gzip"))
+	}
+	return otlploggrpc.New(ctx, opts...)
+}
+
+// newPrometheusReader returns a reader collecting the metrics when they are scraped, and the handler serving them.
+// Each reader has its own registry, so the metrics are not mixed with the ones of the default registry.
+func newPrometheusReader() (metric.Reader, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	reader, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
+
+// servePrometheus serves the metrics on addr until the returned func is called.
+func servePrometheus(addr string, handler http.Handler) (ShutdownFunc, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: healthCheckTimeout}
+	go func() {
+		err := server.Serve(l)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("prometheus server stopped", "error", err)
+		}
+	}()
+	slog.Debug("prometheus server started", "addr", l.Addr().String())
+	return server.Shutdown, nil
+}
+
+// exporterFor returns the config of the exporter used by a signal.
+func (cfg telemetryConfig) exporterFor(signal, name string) (exporterConfig, error) {
+	exp, ok := cfg.Exporters[name]
+	if !ok {
+		return exp, fmt.Errorf("%w: telemetry.%s.exporter not found: %s", ErrConfig, signal, name)
+	}
+	if exp.Type == exporterPrometheus && signal != "metric" {
+		return exp, fmt.Errorf("%w: telemetry.%s.exporter %s: the prometheus exporter only supports metrics", ErrConfig, signal, name)
+	}
+	return exp, nil
+}
@@ -0,0 +1,199 @@
+package app_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+)
+
+// fakeCollector records the OTLP/HTTP requests by path.
+type fakeCollector struct {
+	mu       sync.Mutex
+	requests map[string][][]byte
+}
+
+func (c *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	b, _ := io.ReadAll(body)
+	if r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("X-Token") != "secret" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests[r.URL.Path] = append(c.requests[r.URL.Path], b)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+}
+
+func (c *fakeCollector) received(path, content string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, b := range c.requests[path] {
+		if bytes.Contains(b, []byte(content)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	for _, compression := range []string{"gzip", "none"} {
+		t.Run(compression, func(t *testing.T) {
+			collector := &fakeCollector{requests: make(map[string][][]byte)}
+			srv := httptest.NewServer(collector)
+			defer srv.Close()
+
+			config := fmt.Sprintf(`telemetry:
+  tracer: {exporter: collector}
+  metric: {exporter: collector}
+  logger: {exporter: collector}
+  exporters:
+    collector:
+      protocol: http/protobuf
+      endpoint: %s
+      compression: %s
+      timeout: 5s
+      headers:
+        x-token: secret
+      paths:
+        logs: /custom/logs
+      retry:
+        enabled: false
+`, srv.URL, compression)
+			if err := executeWithConfig(t, telemetryCommand(), config); err != nil {
+				t.Fatal(err)
+			}
+
+			expect := map[string]string{
+				"/v1/traces":   "test-span",
+				"/v1/metrics":  "test.counter",
+				"/custom/logs": "test-log",
+			}
+			for path, content := range expect {
+				if !collector.received(path, content) {
+					t.Errorf("expect %s to receive %q", path, content)
+				}
+			}
+		})
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	telemetryFile := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	config := fmt.Sprintf(`telemetry:
+  tracer: {exporter: file}
+  metric: {exporter: file}
+  logger: {exporter: file}
+  exporters:
+    file:
+      type: file
+      path: %s
+`, telemetryFile)
+	if err := executeWithConfig(t, telemetryCommand(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(telemetryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(b), []byte("\n")) {
+		if !json.Valid(line) {
+			t.Errorf("expect JSON lines, got:\n%s", line)
+		}
+	}
+	for _, s := range []string{`"Name":"test-span"`, `"Name":"test.counter"`, `"Body":{"Type":"String","Value":"test-log"}`} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Errorf("expect %s in the file, got:\n%s", s, b)
+		}
+	}
+}
+
+func TestPrometheusExporter(t *testing.T) {
+	freeAddr := func() string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		return l.Addr().String()
+	}
+
+	for _, admin := range []bool{false, true} {
+		t.Run(fmt.Sprintf("admin=%v", admin), func(t *testing.T) {
+			addr := freeAddr()
+			telemetryFile := filepath.Join(t.TempDir(), "telemetry.jsonl")
+			config := fmt.Sprintf(`telemetry:
+  metric:
+    exporter: file
+    exporters: [prom]
+  exporters:
+    file:
+      type: file
+      path: %s
+    prom:
+      type: prometheus
+`, telemetryFile)
+			if admin {
+				config += "admin:\n  addr: " + addr + "\n"
+			} else {
+				config += "      addr: " + addr + "\n"
+			}
+
+			var scraped []byte
+			root := &cobra.Command{
+				Use: "test",
+				RunE: func(cmd *cobra.Command, args []string) error {
+					counter, _ := otel.Meter("test").Int64Counter("test.counter")
+					counter.Add(cmd.Context(), 1)
+
+					res, err := http.Get("http://" + addr + "/metrics")
+					if err != nil {
+						return err
+					}
+					defer res.Body.Close()
+					scraped, err = io.ReadAll(res.Body)
+					return err
+				},
+			}
+			if err := executeWithConfig(t, root, config); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range []string{"# TYPE test_counter_total counter", "# TYPE go_goroutine_count gauge"} {
+				if !bytes.Contains(scraped, []byte(s)) {
+					t.Errorf("expect %s to be scraped, got:\n%s", s, scraped)
+				}
+			}
+			// the OTLP style exporters keep pushing along with the prometheus one
+			b, err := os.ReadFile(telemetryFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(b, []byte(`"Name":"test.counter"`)) {
+				t.Errorf("expect the file exporter to receive test.counter, got:\n%s", b)
+			}
+		})
+	}
+}
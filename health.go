@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+type HealthCheckFunc func(ctx context.Context) error
+
+// AdminComponent is the name of the component serving the admin endpoints.
+const AdminComponent = "admin"
+
+const healthCheckTimeout = 5 * time.Second
+
+// AddReadinessCheck adds a check to /readyz and /healthz. Running components are always checked.
+func AddReadinessCheck(name string, fn HealthCheckFunc) {
+	defaultApp.AddReadinessCheck(name, fn)
+}
+
+// AddLivenessCheck adds a check to /livez and /healthz.
+func AddLivenessCheck(name string, fn HealthCheckFunc) {
+	defaultApp.AddLivenessCheck(name, fn)
+}
+
+func (a *App) AddReadinessCheck(name string, fn HealthCheckFunc) {
+	a.healthMu.Lock()
+	defer a.healthMu.Unlock()
+	a.readinessChecks[name] = fn
+}
+
+func (a *App) AddLivenessCheck(name string, fn HealthCheckFunc) {
+	a.healthMu.Lock()
+	defer a.healthMu.Unlock()
+	a.livenessChecks[name] = fn
+}
+
+// markShuttingDown makes /readyz fail, so load balancers stop sending traffic before the application stops.
+func (a *App) markShuttingDown() {
+	a.shuttingDown.Store(true)
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func (a *App) healthHandler(readiness, liveness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		results := make(map[string]error)
+		if readiness {
+			for name, err := range a.components.health(ctx) {
+				results[name] = err
+			}
+		}
+
+		a.healthMu.Lock()
+		checks := make(map[string]HealthCheckFunc)
+		if readiness {
+			for name, fn := range a.readinessChecks {
+				checks[name] = fn
+			}
+		}
+		if liveness {
+			for name, fn := range a.livenessChecks {
+				checks[name] = fn
+			}
+		}
+		a.healthMu.Unlock()
+		for name, fn := range checks {
+			results[name] = fn(ctx)
+		}
+		if readiness && a.shuttingDown.Load() {
+			results["shutdown"] = errors.New("application is shutting down")
+		}
+
+		res := healthResponse{Status: "ok", Checks: make(map[string]string, len(results))}
+		code := http.StatusOK
+		for name, err := range results {
+			res.Checks[name] = "ok"
+			if err != nil {
+				res.Checks[name] = err.Error()
+				res.Status = "fail"
+				code = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
+
+// metricsHandler serves the metrics of the prometheus exporter configured without addr.
+func (a *App) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if c, ok := a.LookupComponent(TelemetryComponent); ok {
+		if t, ok := c.(*telemetry); ok && t.metrics != nil {
+			t.metrics.ServeHTTP(w, r)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+type admin struct {
+	addr   string
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+func (a *App) newAdmin(addr string) *admin {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", a.healthHandler(true, true))
+	mux.Handle("/readyz", a.healthHandler(true, false))
+	mux.Handle("/livez", a.healthHandler(false, true))
+	mux.HandleFunc("/metrics", a.metricsHandler)
+	mux.HandleFunc("/loglevel", a.logLevelHandler)
+	return &admin{addr: addr, mux: mux}
+}
+
+func (a *admin) Start(ctx context.Context) error {
+	l, err := net.Listen("tcp", a.addr)
+	if err != nil {
+		return err
+	}
+	a.server = &http.Server{Handler: a.mux, ReadHeaderTimeout: healthCheckTimeout}
+	go func() {
+		err := a.server.Serve(l)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("admin server stopped", "error", err)
+		}
+	}()
+	slog.Debug("admin server started", "addr", l.Addr().String())
+	return nil
+}
+
+func (a *admin) Stop(ctx context.Context) error {
+	return a.server.Shutdown(ctx)
+}
+
+func (a *admin) Health(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	a := New()
+	a.AddLivenessCheck("loop", func(ctx context.Context) error { return nil })
+	a.AddReadinessCheck("queue", func(ctx context.Context) error { return errors.New("queue is full") })
+
+	testData := []struct {
+		Path   string
+		Code   int
+		Checks map[string]string
+	}{
+		{"/livez", http.StatusOK, map[string]string{"loop": "ok"}},
+		{"/readyz", http.StatusServiceUnavailable, map[string]string{"queue": "queue is full"}},
+		{"/healthz", http.StatusServiceUnavailable, map[string]string{"loop": "ok", "queue": "queue is full"}},
+	}
+
+	handler := a.newAdmin("").mux
+	for i, v := range testData {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, v.Path, nil))
+		if rec.Code != v.Code {
+			t.Errorf("\nscenario #%v, expect code %v, got %v", i+1, v.Code, rec.Code)
+		}
+		var res healthResponse
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		for name, status := range v.Checks {
+			if res.Checks[name] != status {
+				t.Errorf("\nscenario #%v, expect %v to be %q, got %q", i+1, name, status, res.Checks[name])
+			}
+		}
+	}
+
+	delete(a.readinessChecks, "queue")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expect ready, got %v", rec.Code)
+	}
+
+	a.markShuttingDown()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect not ready while shutting down, got %v", rec.Code)
+	}
+}
+
+func TestLogLevelEndpoint(t *testing.T) {
+	a := New()
+	handler := a.newAdmin("").mux
+
+	testData := []struct {
+		Method string
+		Body   string
+		Code   int
+		Expect logLevelsResponse
+	}{
+		{http.MethodGet, "", http.StatusOK, logLevelsResponse{Level: "INFO", Loggers: map[string]string{}}},
+		{http.MethodPut, `{"level":"debug","loggers":{"pgx":"warn","consul":"error"}}`, http.StatusOK, logLevelsResponse{Level: "DEBUG", Loggers: map[string]string{"pgx": "WARN", "consul": "ERROR"}}},
+		{http.MethodPut, `{"loggers":{"pgx":""}}`, http.StatusOK, logLevelsResponse{Level: "DEBUG", Loggers: map[string]string{"consul": "ERROR"}}},
+		{http.MethodPut, `{"level":"verbose"}`, http.StatusBadRequest, logLevelsResponse{}},
+		{http.MethodPost, "", http.StatusMethodNotAllowed, logLevelsResponse{}},
+	}
+	for i, v := range testData {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(v.Method, "/loglevel", strings.NewReader(v.Body)))
+		if rec.Code != v.Code {
+			t.Fatalf("\nscenario #%v, expect code %v, got %v: %s", i+1, v.Code, rec.Code, rec.Body)
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var res logLevelsResponse
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Loggers == nil {
+			res.Loggers = map[string]string{}
+		}
+		if !reflect.DeepEqual(res, v.Expect) {
+			t.Errorf("\nscenario #%v, expect %+v, got %+v", i+1, v.Expect, res)
+		}
+	}
+
+	if a.logLevels.levelOf("consul") != slog.LevelError || a.logLevels.levelOf("pgx") != slog.LevelDebug {
+		t.Errorf("expect the levels to be applied, got %+v", a.logLevels.loggers)
+	}
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Info describes the application. Empty fields are filled from the build information embedded by the go toolchain.
+type Info struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+	Commit      string `json:"commit,omitempty"`
+	BuildDate   string `json:"build_date,omitempty"`
+}
+
+func SetInfo(i Info) {
+	defaultApp.SetInfo(i)
+}
+
+func (a *App) SetInfo(i Info) {
+	a.info = i
+}
+
+func (a *App) appInfo() Info {
+	i := a.info
+	bi, ok := debug.ReadBuildInfo()
+
+	if i.Name == "" && a.rootCmd != nil {
+		i.Name = a.rootCmd.Name()
+	}
+	if i.Name == "" && ok && bi.Path != "" {
+		i.Name = path.Base(bi.Path)
+	}
+	if i.Name == "" {
+		i.Name = filepath.Base(os.Args[0])
+	}
+	if i.Description == "" && a.rootCmd != nil {
+		i.Description = a.rootCmd.Short
+	}
+	if ok {
+		i = withBuildInfo(i, bi)
+	}
+	if i.Version == "" {
+		i.Version = "dev"
+	}
+	return i
+}
+
+func withBuildInfo(i Info, bi *debug.BuildInfo) Info {
+	if i.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		i.Version = bi.Main.Version
+	}
+	modified, revision := false, false
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if i.Commit == "" {
+				i.Commit = s.Value
+				revision = true
+			}
+		case "vcs.time":
+			if i.BuildDate == "" {
+				i.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if modified && revision {
+		i.Commit += "-dirty"
+	}
+	return i
+}
+
+func (a *App) versionCommand() *cobra.Command {
+	output := "text"
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version information",
+		Args:  cobra.NoArgs,
+		// version does not need config nor telemetry
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			i := a.appInfo()
+			out := cmd.OutOrStdout()
+			switch output {
+			case "json":
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(struct {
+					Info
+					GoVersion string `json:"go_version"`
+				}{i, runtime.Version()})
+			case "text":
+				fmt.Fprintf(out, "%s %s\n", i.Name, i.Version)
+				if i.Commit != "" {
+					fmt.Fprintf(out, "commit:     %s\n", i.Commit)
+				}
+				if i.BuildDate != "" {
+					fmt.Fprintf(out, "build date: %s\n", i.BuildDate)
+				}
+				fmt.Fprintf(out, "go version: %s\n", runtime.Version())
+				return nil
+			default:
+				return errors.New("unknown output format: " + output)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "output format: text, json")
+	return cmd
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestVersionCommand(t *testing.T) {
+	a := New(WithInfo(Info{Name: "myapp", Version: "v1.2.3", Commit: "abcdef"}))
+
+	testData := []struct {
+		Args   []string
+		Expect string
+	}{
+		{[]string{}, "myapp v1.2.3\ncommit:     abcdef\n"},
+		{[]string{"-o", "text"}, "myapp v1.2.3\n"},
+	}
+	for i, v := range testData {
+		out := &bytes.Buffer{}
+		cmd := a.versionCommand()
+		cmd.SetOut(out)
+		cmd.SetArgs(v.Args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(out.Bytes(), []byte(v.Expect)) {
+			t.Errorf("\nscenario #%v, expect output to contain %q, got %q", i+1, v.Expect, out.String())
+		}
+	}
+
+	out := &bytes.Buffer{}
+	cmd := a.versionCommand()
+	cmd.SetOut(out)
+	cmd.SetArgs([]string{"-o", "json"})
+	_ = cmd.Execute()
+	var res Info
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil || res.Name != "myapp" || res.Commit != "abcdef" {
+		t.Errorf("invalid json output: %v %+v", err, res)
+	}
+
+	cmd = a.versionCommand()
+	cmd.SetArgs([]string{"-o", "xml"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expect unknown output format to fail")
+	}
+}
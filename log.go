@@ -0,0 +1,358 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lmittmann/tint"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	logFormatText   = "text"
+	logFormatJSON   = "json"
+	logFormatPretty = "console-pretty"
+
+	logOutputStdout = "stdout"
+	logOutputStderr = "stderr"
+	logOutputFile   = "file"
+
+	// LoggerKey is the attribute naming a logger, eg: slog.With(app.LoggerKey, "pgx"),
+	// so its level can be set apart with telemetry.logger.levels.
+	LoggerKey = "logger"
+)
+
+type loggerConfig struct {
+	observabilityConfig `mapstructure:",squash"`
+
+	// Level is the minimum level of the logs, Levels overrides it by logger name
+	Level  string            `mapstructure:"level" default:"info" validate:"oneof=debug info warn error"`
+	Levels map[string]string `mapstructure:"levels"`
+	// Format of the logs written to Output, console-pretty is colored when Output is a terminal
+	Format string `mapstructure:"format" default:"text" validate:"oneof=text json console-pretty"`
+	// Output is stdout, stderr, or file to write to Path
+	Output   string            `mapstructure:"output" default:"stdout" validate:"oneof=stdout stderr file"`
+	Path     string            `mapstructure:"path"`
+	Rotation logRotationConfig `mapstructure:"rotation"`
+	// Source adds the file and line of the log call
+	Source bool `mapstructure:"source"`
+	// Baggage are the members of the baggage added to the logs written to Output, along with the trace context
+	Baggage []string `mapstructure:"baggage"`
+}
+
+type logRotationConfig struct {
+	// MaxSize is the size in megabytes of the file before it is rotated
+	MaxSize int `mapstructure:"max_size" default:"100" validate:"min=1"`
+	// MaxAge is the number of days to keep the rotated files, they are kept forever when 0
+	MaxAge     int  `mapstructure:"max_age" validate:"min=0"`
+	MaxBackups int  `mapstructure:"max_backups" validate:"min=0"`
+	Compress   bool `mapstructure:"compress"`
+}
+
+// levels returns the level of the logs and the levels by logger name, flag takes precedence over the config level.
+func (c loggerConfig) levels(flag string) (slog.Level, map[string]slog.Level, error) {
+	name := c.Level
+	if flag != "" {
+		name = flag
+	}
+	level, err := parseLevel(name)
+	if err != nil {
+		return level, nil, fmt.Errorf("%w: invalid log level: %w", ErrConfig, err)
+	}
+	loggers := make(map[string]slog.Level, len(c.Levels))
+	for logger, name := range c.Levels {
+		l, err := parseLevel(name)
+		if err != nil {
+			return level, nil, fmt.Errorf("%w: telemetry.logger.levels.%s: %w", ErrConfig, logger, err)
+		}
+		loggers[logger] = l
+	}
+	return level, loggers, nil
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+// open returns the destination of the logs, the file is rotated as configured.
+func (c loggerConfig) open() (io.WriteCloser, error) {
+	switch c.Output {
+	case logOutputStderr:
+		return nopCloser{os.Stderr}, nil
+	case logOutputFile:
+		if c.Path == "" {
+			return nil, fmt.Errorf("%w: the file output of the logger requires a path", ErrConfig)
+		}
+		return &lumberjack.Logger{
+			Filename:   c.Path,
+			MaxSize:    c.Rotation.MaxSize,
+			MaxAge:     c.Rotation.MaxAge,
+			MaxBackups: c.Rotation.MaxBackups,
+			Compress:   c.Rotation.Compress,
+		}, nil
+	default:
+		return nopCloser{os.Stdout}, nil
+	}
+}
+
+// handler returns the handler writing the logs to w, the levels are checked by levelHandler.
+func (c loggerConfig) handler(w io.Writer) slog.Handler {
+	h := c.formatHandler(w)
+	return traceHandler{Handler: h, root: h, baggage: c.Baggage}
+}
+
+func (c loggerConfig) formatHandler(w io.Writer) slog.Handler {
+	level := slog.Level(math.MinInt)
+	switch c.Format {
+	case logFormatJSON:
+		return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, AddSource: c.Source})
+	case logFormatPretty:
+		f, ok := w.(*os.File)
+		if nc, isNop := w.(nopCloser); isNop {
+			f, ok = nc.Writer.(*os.File)
+		}
+		return tint.NewHandler(w, &tint.Options{
+			Level:      level,
+			AddSource:  c.Source,
+			TimeFormat: time.TimeOnly,
+			NoColor:    !ok || !isTerminal(f),
+		})
+	default:
+		return slog.NewTextHandler(w, &slog.HandlerOptions{Level: level, AddSource: c.Source})
+	}
+}
+
+// traceHandler adds the trace context of the records to the logs written to the output,
+// the records sent to the exporters are already linked to their span.
+type traceHandler struct {
+	slog.Handler
+	// root is the handler before the first group, and scope the groups and attributes added after it,
+	// so the trace context is added at the top level rather than in the group.
+	root    slog.Handler
+	scope   []func(slog.Handler) slog.Handler
+	baggage []string
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, 3+len(h.baggage))
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs,
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+			slog.Bool("sampled", sc.IsSampled()),
+		)
+	}
+	if len(h.baggage) > 0 {
+		b := baggage.FromContext(ctx)
+		for _, key := range h.baggage {
+			if m := b.Member(key); m.Key() != "" {
+				attrs = append(attrs, slog.String(key, m.Value()))
+			}
+		}
+	}
+	if len(attrs) == 0 {
+		return h.Handler.Handle(ctx, r)
+	}
+	if len(h.scope) > 0 {
+		handler := h.root.WithAttrs(attrs)
+		for _, fn := range h.scope {
+			handler = fn(handler)
+		}
+		return handler.Handle(ctx, r)
+	}
+	// the record is shared with the other handlers
+	r = r.Clone()
+	r.AddAttrs(attrs...)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(h.scope) == 0 {
+		handler := h.Handler.WithAttrs(attrs)
+		return traceHandler{Handler: handler, root: handler, baggage: h.baggage}
+	}
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h traceHandler) with(fn func(slog.Handler) slog.Handler) traceHandler {
+	scope := append(h.scope[:len(h.scope):len(h.scope)], fn)
+	return traceHandler{Handler: fn(h.Handler), root: h.root, scope: scope, baggage: h.baggage}
+}
+
+// logLevels holds the minimum level of the logs, and the levels overriding it by logger name.
+type logLevels struct {
+	mu      sync.RWMutex
+	level   slog.Level
+	loggers map[string]slog.Level
+}
+
+func (l *logLevels) set(level slog.Level, loggers map[string]slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+	l.loggers = loggers
+}
+
+// update sets the level unless empty, and the levels of loggers, an empty level removes the one of a logger.
+func (l *logLevels) update(level string, loggers map[string]string) (slog.Level, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	updated, changes := l.level, make(map[string]slog.Level, len(loggers))
+	var err error
+	if level != "" {
+		if updated, err = parseLevel(level); err != nil {
+			return updated, err
+		}
+	}
+	for logger, name := range loggers {
+		if name == "" {
+			continue
+		}
+		if changes[logger], err = parseLevel(name); err != nil {
+			return updated, fmt.Errorf("%s: %w", logger, err)
+		}
+	}
+
+	l.level = updated
+	l.loggers = maps.Clone(l.loggers)
+	if l.loggers == nil {
+		l.loggers = make(map[string]slog.Level)
+	}
+	for logger, name := range loggers {
+		if name == "" {
+			delete(l.loggers, logger)
+		}
+	}
+	maps.Copy(l.loggers, changes)
+	return updated, nil
+}
+
+// levelOf returns the level of a logger, the global level is used by unnamed loggers.
+func (l *logLevels) levelOf(logger string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level, ok := l.loggers[logger]; ok && logger != "" {
+		return level
+	}
+	return l.level
+}
+
+// min returns the lowest level, logs under it are not enabled whatever their logger.
+func (l *logLevels) min() slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	level := l.level
+	for _, v := range l.loggers {
+		level = min(level, v)
+	}
+	return level
+}
+
+// levelHandler drops the logs under the level of their logger, named by the LoggerKey attribute.
+type levelHandler struct {
+	levels  *logLevels
+	logger  string
+	grouped bool
+	next    slog.Handler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	// the logger of unnamed handlers is only known once the record attributes are read
+	if h.logger == "" {
+		return level >= h.levels.min() && h.next.Enabled(ctx, level)
+	}
+	return level >= h.levels.levelOf(h.logger) && h.next.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	logger := h.logger
+	if logger == "" && !h.grouped {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == LoggerKey {
+				logger = a.Value.String()
+				return false
+			}
+			return true
+		})
+	}
+	if r.Level < h.levels.levelOf(logger) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.next = h.next.WithAttrs(attrs)
+	if !h.grouped {
+		for _, a := range attrs {
+			if a.Key == LoggerKey {
+				c.logger = a.Value.String()
+			}
+		}
+	}
+	return &c
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.next = h.next.WithGroup(name)
+	c.grouped = true
+	return &c
+}
+
+type logLevelsResponse struct {
+	Level   string            `json:"level"`
+	Loggers map[string]string `json:"loggers,omitempty"`
+}
+
+// logLevelHandler shows the log levels, and changes them on PUT.
+// An empty level removes the level of a logger.
+func (a *App) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	levels := a.logLevels
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req logLevelsResponse
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		level, err := levels.update(req.Level, req.Loggers)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info("log levels changed", "level", level, "loggers", req.Loggers)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	levels.mu.RLock()
+	res := logLevelsResponse{Level: levels.level.String(), Loggers: make(map[string]string, len(levels.loggers))}
+	for logger, level := range levels.loggers {
+		res.Loggers[logger] = level.String()
+	}
+	levels.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,157 @@
+package app_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoggerConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		expect []string
+	}{
+		{
+			name:   "config levels",
+			expect: []string{"warn", "pgx debug", "pgx inline debug"},
+		},
+		{
+			name:   "flag",
+			args:   []string{"--log-level", "error"},
+			expect: []string{"pgx debug", "pgx inline debug"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logFile := filepath.Join(t.TempDir(), "app.log")
+			config := fmt.Sprintf(`telemetry:
+  logger:
+    level: warn
+    levels:
+      pgx: debug
+    format: json
+    output: file
+    path: %s
+    source: true
+`, logFile)
+
+			root := &cobra.Command{
+				Use: "test",
+				Run: func(cmd *cobra.Command, args []string) {
+					slog.Info("info")
+					slog.Warn("warn")
+					slog.With(app.LoggerKey, "pgx").Debug("pgx debug")
+					slog.Debug("pgx inline debug", app.LoggerKey, "pgx")
+					slog.With(app.LoggerKey, "consul").Debug("consul debug")
+				},
+			}
+			if err := executeWithConfig(t, root, config, tt.args...); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(logFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var messages []string
+			for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+				var record struct {
+					Msg    string         `json:"msg"`
+					Source map[string]any `json:"source"`
+				}
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("expect JSON lines, got %v: %s", err, line)
+				}
+				if record.Source["file"] == nil {
+					t.Errorf("expect the source of %q", record.Msg)
+				}
+				messages = append(messages, record.Msg)
+			}
+			if !reflect.DeepEqual(messages, tt.expect) {
+				t.Errorf("expect %q, got %q", tt.expect, messages)
+			}
+		})
+	}
+}
+
+func TestLoggerTraceContext(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	config := fmt.Sprintf(`telemetry:
+  logger:
+    format: json
+    output: file
+    path: %s
+    baggage: [tenant, missing]
+`, logFile)
+
+	root := &cobra.Command{
+		Use: "test",
+		Run: func(cmd *cobra.Command, args []string) {
+			sc := trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{1},
+				SpanID:     trace.SpanID{2},
+				TraceFlags: trace.FlagsSampled,
+			})
+			ctx := trace.ContextWithSpanContext(cmd.Context(), sc)
+			tenant, _ := baggage.NewMember("tenant", "acme")
+			b, _ := baggage.New(tenant)
+			ctx = baggage.ContextWithBaggage(ctx, b)
+
+			slog.InfoContext(ctx, "traced")
+			slog.Default().WithGroup("request").With("method", "GET").InfoContext(ctx, "grouped", "path", "/")
+			slog.Info("untraced")
+		},
+	}
+	if err := executeWithConfig(t, root, config); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make(map[string]map[string]any)
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("expect JSON lines, got %v: %s", err, line)
+		}
+		records[record["msg"].(string)] = record
+	}
+
+	expect := map[string]any{
+		"trace_id": "01000000000000000000000000000000",
+		"span_id":  "0200000000000000",
+		"sampled":  true,
+		"tenant":   "acme",
+	}
+	for k, v := range expect {
+		if got := records["traced"][k]; got != v {
+			t.Errorf("expect %s to be %v, got %v", k, v, got)
+		}
+		// the trace context stays at the top level of grouped records
+		if got := records["grouped"][k]; got != v {
+			t.Errorf("expect %s of the grouped record to be %v, got %v", k, v, got)
+		}
+		if got, ok := records["untraced"][k]; ok {
+			t.Errorf("expect no %s without trace context, got %v", k, got)
+		}
+	}
+	if group, _ := records["grouped"]["request"].(map[string]any); group["method"] != "GET" || group["path"] != "/" || len(group) != 2 {
+		t.Errorf("expect the attributes of the grouped record in its group, got %v", records["grouped"])
+	}
+	if _, ok := records["traced"]["missing"]; ok {
+		t.Errorf("expect no missing baggage member, got %v", records["traced"])
+	}
+}
@@ -0,0 +1,204 @@
+package app
+
+import (
+	"log/slog"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// otelEnvConfig returns the telemetry settings described by the OpenTelemetry environment variables,
+// see https://opentelemetry.io/docs/specs/otel/configuration/sdk-environment-variables/.
+// They fill the keys not set in config: the exporters of a signal are only read when config has none.
+// Unsupported samplers and propagators are ignored with a warning.
+func otelEnvConfig(config *viper.Viper) map[string]any {
+	settings := make(map[string]any)
+	set := func(key, env string, value any) {
+		if v := os.Getenv(env); v != "" && !config.IsSet("telemetry."+key) {
+			if value == nil {
+				value = v
+			}
+			setPath(settings, key, value)
+		}
+	}
+
+	set("service_name", "OTEL_SERVICE_NAME", nil)
+	if sampler := os.Getenv("OTEL_TRACES_SAMPLER"); sampler != "" && !slices.Contains(supportedValues("Sampler"), sampler) {
+		slog.Warn("unsupported sampler ignored", "env", "OTEL_TRACES_SAMPLER", "sampler", sampler)
+	} else {
+		set("tracer.sampler", "OTEL_TRACES_SAMPLER", nil)
+	}
+	set("tracer.sampler_ratio", "OTEL_TRACES_SAMPLER_ARG", nil)
+	var propagators []string
+	for _, name := range splitList(os.Getenv("OTEL_PROPAGATORS")) {
+		if !slices.Contains(supportedValues("Propagators"), name) {
+			slog.Warn("unsupported propagator ignored", "env", "OTEL_PROPAGATORS", "propagator", name)
+			continue
+		}
+		propagators = append(propagators, name)
+	}
+	if len(propagators) > 0 {
+		set("tracer.propagators", "OTEL_PROPAGATORS", propagators)
+	}
+
+	// without OTEL_*_EXPORTER, OTLP is the default once its endpoint is set
+	defaultExporter := ""
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		defaultExporter = exporterOTLP
+	}
+	exporters := make(map[string]bool)
+	for _, s := range []struct{ config, env, otlp string }{
+		{"tracer", "OTEL_TRACES_EXPORTER", "traces"},
+		{"metric", "OTEL_METRICS_EXPORTER", "metrics"},
+		{"logger", "OTEL_LOGS_EXPORTER", "logs"},
+	} {
+		if config.IsSet("telemetry."+s.config+".exporter") || config.IsSet("telemetry."+s.config+".exporters") {
+			continue
+		}
+		// the signal specific variables take precedence, eg: OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+		signalOTLP := otlpEnvConfig("OTEL_EXPORTER_OTLP_" + strings.ToUpper(s.otlp) + "_")
+		names := splitList(os.Getenv(s.env))
+		if len(names) == 0 && (defaultExporter != "" || signalOTLP["endpoint"] != nil) {
+			names = []string{exporterOTLP}
+		}
+		var used []string
+		for _, name := range names {
+			// none disables the signal, logging is the deprecated name of console
+			if name == "none" {
+				used = nil
+				break
+			}
+			if name == "logging" {
+				name = "console"
+			}
+			used = append(used, name)
+		}
+		for i, name := range used {
+			if name != exporterOTLP || len(signalOTLP) == 0 || config.IsSet("telemetry.exporters."+exporterOTLP) {
+				exporters[name] = true
+				continue
+			}
+			// the signal gets its own exporter, eg: otlp_traces, with the specific variables over the generic ones
+			used[i] = exporterOTLP + "_" + s.otlp
+			if !config.IsSet("telemetry.exporters." + used[i]) {
+				exp := otlpEnvExporter()
+				if signalOTLP["endpoint"] != nil {
+					// the signal specific endpoint is used as is
+					delete(exp, "paths")
+				}
+				maps.Copy(exp, signalOTLP)
+				setPath(settings, "exporters."+used[i], exp)
+			}
+		}
+		if len(used) > 0 {
+			setPath(settings, s.config+".exporters", used)
+		}
+	}
+
+	for name := range exporters {
+		if config.IsSet("telemetry.exporters." + name) {
+			continue
+		}
+		switch name {
+		case exporterOTLP:
+			setPath(settings, "exporters.otlp", otlpEnvExporter())
+		case "console":
+			setPath(settings, "exporters.console", map[string]any{"type": exporterStdout})
+		case exporterPrometheus:
+			host, port := os.Getenv("OTEL_EXPORTER_PROMETHEUS_HOST"), os.Getenv("OTEL_EXPORTER_PROMETHEUS_PORT")
+			if host == "" {
+				host = "localhost"
+			}
+			if port == "" {
+				port = "9464"
+			}
+			setPath(settings, "exporters.prometheus", map[string]any{"type": exporterPrometheus, "addr": net.JoinHostPort(host, port)})
+		}
+	}
+	return settings
+}
+
+// otlpEnvExporter returns the settings of the OTLP exporter set by the generic variables. As in the SDKs,
+// the protocol defaults to http/protobuf, and the signal paths are appended to the path of the endpoint,
+// eg: http://collector:4318/otlp sends the traces to /otlp/v1/traces.
+func otlpEnvExporter() map[string]any {
+	exp := otlpEnvConfig("OTEL_EXPORTER_OTLP_")
+	exp["type"] = exporterOTLP
+	if exp["protocol"] == nil {
+		exp["protocol"] = "http/protobuf"
+	}
+	if endpoint, ok := exp["endpoint"].(string); ok {
+		if u, err := url.Parse(endpoint); err == nil && strings.Trim(u.Path, "/") != "" {
+			path := strings.TrimSuffix(u.Path, "/")
+			exp["paths"] = map[string]any{
+				"traces":  path + "/v1/traces",
+				"metrics": path + "/v1/metrics",
+				"logs":    path + "/v1/logs",
+			}
+		}
+	}
+	return exp
+}
+
+// otlpEnvConfig returns the settings of the OTLP exporter set by the variables starting with prefix,
+// eg: OTEL_EXPORTER_OTLP_ for all the signals, or OTEL_EXPORTER_OTLP_TRACES_ for the traces only.
+func otlpEnvConfig(prefix string) map[string]any {
+	exp := make(map[string]any)
+	for key, env := range map[string]string{
+		"endpoint":    "ENDPOINT",
+		"protocol":    "PROTOCOL",
+		"compression": "COMPRESSION",
+		"insecure":    "INSECURE",
+	} {
+		if v := os.Getenv(prefix + env); v != "" {
+			exp[key] = v
+		}
+	}
+	if v := os.Getenv(prefix + "TIMEOUT"); v != "" {
+		// in milliseconds
+		exp["timeout"] = v + "ms"
+	}
+	if v := os.Getenv(prefix + "HEADERS"); v != "" {
+		headers := make(map[string]any)
+		for _, h := range strings.Split(v, ",") {
+			k, v, ok := strings.Cut(h, "=")
+			if !ok {
+				continue
+			}
+			if unescaped, err := url.PathUnescape(strings.TrimSpace(v)); err == nil {
+				v = unescaped
+			}
+			headers[strings.TrimSpace(k)] = v
+		}
+		exp["headers"] = headers
+	}
+	return exp
+}
+
+// supportedValues returns the values allowed by the oneof rule of a field of tracerConfig.
+func supportedValues(field string) []string {
+	f, _ := reflect.TypeFor[tracerConfig]().FieldByName(field)
+	for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+		if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+			return strings.Fields(values)
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated list.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
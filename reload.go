@@ -0,0 +1,239 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// ConfigValidator checks a config before it is used, either on start or on reload.
+type ConfigValidator func(cfg *viper.Viper) error
+
+// ConfigChangeFunc is called with the new config after a reload changed the keys it subscribed to.
+type ConfigChangeFunc func(ctx context.Context, cfg *viper.Viper)
+
+type configSubscriber struct {
+	prefix string
+	fn     ConfigChangeFunc
+}
+
+// configReloadDebounce groups the events of a single file save, editors often write a file in several steps.
+const configReloadDebounce = 100 * time.Millisecond
+
+// SetConfigReload enables reloading the config when the config file changes or the process receives SIGHUP.
+// It can also be enabled with the config key config.reload.
+func SetConfigReload(enabled bool) {
+	defaultApp.SetConfigReload(enabled)
+}
+
+// AddConfigValidator adds a validator run on every config load. An invalid config fails the start with ErrConfig,
+// and is discarded on reload.
+func AddConfigValidator(fn ConfigValidator) {
+	defaultApp.AddConfigValidator(fn)
+}
+
+// OnConfigChange calls fn when a reload changes a key under prefix, eg: telemetry.* or pgx.example.
+// An empty prefix, or *, matches any change.
+func OnConfigChange(prefix string, fn ConfigChangeFunc) {
+	defaultApp.OnConfigChange(prefix, fn)
+}
+
+func WithConfigReload(enabled bool) Option {
+	return func(a *App) {
+		a.configReload = enabled
+	}
+}
+
+func (a *App) SetConfigReload(enabled bool) {
+	a.configReload = enabled
+}
+
+func (a *App) AddConfigValidator(fn ConfigValidator) {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+	a.configValidators = append(a.configValidators, fn)
+}
+
+func (a *App) OnConfigChange(prefix string, fn ConfigChangeFunc) {
+	prefix = strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(prefix, "*"), "."))
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+	a.configSubscribers = append(a.configSubscribers, configSubscriber{prefix: prefix, fn: fn})
+}
+
+func (a *App) validateConfig(cfg *viper.Viper) error {
+	a.configMu.Lock()
+	validators := append([]ConfigValidator{}, a.configValidators...)
+	a.configMu.Unlock()
+
+	for _, t := range a.configTypes.types() {
+		validators = append(validators, t.validate)
+	}
+
+	var errs []error
+	for _, fn := range validators {
+		if err := fn(cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// watchConfig starts the workers reloading the config when its sources change.
+func (a *App) watchConfig() error {
+	if a.consul != nil && a.consul.watch {
+		a.Supervise("consul-watch", a.watchConsul(a.consul))
+	}
+	if !a.configReload && !a.config.Load().GetBool("config.reload") {
+		return nil
+	}
+	// subscribed before the worker starts, so a SIGHUP sent right after the start is not lost
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	a.Supervise("config-signal", a.watchSignal(signals))
+	if len(a.loaded.Load().files) > 0 {
+		watcher, err := a.newConfigWatcher()
+		if err != nil {
+			return err
+		}
+		a.Supervise("config-watch", a.watchFiles(watcher))
+	}
+	return nil
+}
+
+// newConfigWatcher watches the directories of the config files,
+// so the files can be replaced, eg: by editors or kubernetes config maps.
+func (a *App) newConfigWatcher() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range a.loaded.Load().files {
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			_ = watcher.Close()
+			return nil, fmt.Errorf("watch config file: %w", err)
+		}
+	}
+	return watcher, nil
+}
+
+// watchSignal reloads the config when the process receives SIGHUP.
+func (a *App) watchSignal(signals chan os.Signal) WorkerFunc {
+	return func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				signal.Stop(signals)
+				return nil
+			case <-signals:
+				slog.InfoContext(ctx, "SIGHUP received, reloading config")
+				_ = a.reloadConfig(ctx)
+			}
+		}
+	}
+}
+
+// watchFiles reloads the config when one of the config files is written, created or replaced.
+func (a *App) watchFiles(watcher *fsnotify.Watcher) WorkerFunc {
+	return func(ctx context.Context) error {
+		// the watcher is closed when the worker returns, a restarted worker creates a new one
+		if watcher == nil {
+			var err error
+			if watcher, err = a.newConfigWatcher(); err != nil {
+				return err
+			}
+		}
+		defer func() {
+			_ = watcher.Close()
+			watcher = nil
+		}()
+		timer := time.NewTimer(0)
+		<-timer.C
+		var changed string
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return errors.New("config watcher closed")
+				}
+				slog.WarnContext(ctx, "config watcher error", "error", err)
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return errors.New("config watcher closed")
+				}
+				// kubernetes replaces the ..data symlink that the config files point to
+				if !a.isConfigFile(event.Name) && filepath.Base(event.Name) != "..data" {
+					continue
+				}
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
+					changed = event.Name
+					timer.Reset(configReloadDebounce)
+				}
+			case <-timer.C:
+				slog.InfoContext(ctx, "config file changed", "file", changed)
+				_ = a.reloadConfig(ctx)
+			}
+		}
+	}
+}
+
+func (a *App) isConfigFile(name string) bool {
+	for _, file := range a.loaded.Load().files {
+		if filepath.Clean(file) == filepath.Clean(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadConfig replaces the config returned by ConfigFromContext, then notifies the subscribers of the changed keys.
+// The current config is kept when loading or validation fails.
+func (a *App) reloadConfig(ctx context.Context) error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	l, err := a.loadConfig(ctx)
+	if err == nil {
+		err = a.validateConfig(l.config)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "unable to reload config, keeping the current one", "error", err)
+		return err
+	}
+	cfg := l.config
+	old := a.storeConfig(l)
+	slog.InfoContext(ctx, "config reloaded")
+
+	a.configMu.Lock()
+	subscribers := append([]configSubscriber{}, a.configSubscribers...)
+	a.configMu.Unlock()
+	for _, s := range subscribers {
+		if configChanged(s.prefix, old, cfg) {
+			s.fn(ctx, cfg)
+		}
+	}
+	return nil
+}
+
+func configChanged(prefix string, old, cfg *viper.Viper) bool {
+	if old == nil {
+		return true
+	}
+	if prefix == "" {
+		return !reflect.DeepEqual(old.AllSettings(), cfg.AllSettings())
+	}
+	return !reflect.DeepEqual(old.Get(prefix), cfg.Get(prefix))
+}
@@ -0,0 +1,136 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/yeka-go/app"
+)
+
+// waitFor returns an error unless expect is received from ch in time.
+func waitFor(ch <-chan string, expect string) error {
+	select {
+	case got := <-ch:
+		if got != expect {
+			return errors.New("expect " + expect + ", got " + got)
+		}
+		return nil
+	case <-time.After(5 * time.Second):
+		return errors.New("expect " + expect + " to be notified")
+	}
+}
+
+// newReloadApp returns an app notifying the greetings on reload, and the rejected greetings on failed reloads.
+func newReloadApp(root *cobra.Command, greetings, rejected chan<- string, opts ...app.Option) *app.App {
+	a := app.New(append([]app.Option{
+		app.WithRootCommand(root),
+		app.WithConfigReload(true),
+		app.WithEnvFiles(),
+		app.WithArgs(),
+		app.WithOutput(io.Discard, io.Discard),
+	}, opts...)...)
+	a.AddConfigValidator(func(cfg *viper.Viper) error {
+		if cfg.GetString("greeting") == "" {
+			rejected <- "greeting"
+			return errors.New("greeting is required")
+		}
+		return nil
+	})
+	a.OnConfigChange("greeting", func(ctx context.Context, cfg *viper.Viper) {
+		greetings <- cfg.GetString("greeting")
+	})
+	return a
+}
+
+func TestConfigReload(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("greeting: hello\npgx:\n  example:\n    hosts: db:5432\n")
+
+	greetings := make(chan string, 10)
+	rejected := make(chan string, 10)
+	hosts := make(chan string, 10)
+	root := &cobra.Command{
+		Use: "test",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// an invalid config is discarded
+			write("greeting: \"\"\npgx:\n  example:\n    hosts: db:5432\n")
+			if err := waitFor(rejected, "greeting"); err != nil {
+				return err
+			}
+			if got := app.ConfigFromContext(cmd.Context()).GetString("greeting"); got != "hello" {
+				return errors.New("expect the invalid config to be discarded, got greeting " + got)
+			}
+
+			write("greeting: hi\npgx:\n  example:\n    hosts: db:5432\n")
+			if err := waitFor(greetings, "hi"); err != nil {
+				return err
+			}
+			if got := app.ConfigFromContext(cmd.Context()).GetString("greeting"); got != "hi" {
+				return errors.New("expect config to be reloaded, got greeting " + got)
+			}
+
+			write("greeting: hi\npgx:\n  example:\n    hosts: other:5432\n")
+			return waitFor(hosts, "other:5432")
+		},
+	}
+
+	a := newReloadApp(root, greetings, rejected, app.WithConfigFile(configFile))
+	a.OnConfigChange("pgx.*", func(ctx context.Context, cfg *viper.Viper) {
+		hosts <- cfg.GetString("pgx.example.hosts")
+	})
+	if err := a.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigReloadSignal(t *testing.T) {
+	// without config file, there is no file watcher, only SIGHUP reloads the config
+	t.Setenv("TEST_GREETING", "hello")
+
+	greetings := make(chan string, 10)
+	rejected := make(chan string, 10)
+	root := &cobra.Command{
+		Use: "test",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reload := func(greeting string) error {
+				if err := os.Setenv("TEST_GREETING", greeting); err != nil {
+					return err
+				}
+				return syscall.Kill(os.Getpid(), syscall.SIGHUP)
+			}
+
+			if err := reload(""); err != nil {
+				return err
+			}
+			if err := waitFor(rejected, "greeting"); err != nil {
+				return err
+			}
+			if got := app.ConfigFromContext(cmd.Context()).GetString("greeting"); got != "hello" {
+				return errors.New("expect the invalid config to be discarded, got greeting " + got)
+			}
+
+			if err := reload("hi"); err != nil {
+				return err
+			}
+			return waitFor(greetings, "hi")
+		},
+	}
+
+	a := newReloadApp(root, greetings, rejected, app.WithEnvPrefix("TEST"))
+	if err := a.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+type resourceConfig struct {
+	// Attributes are added to the resource, eg: deployment.environment.name, service.namespace or team
+	Attributes map[string]any `mapstructure:"attributes"`
+	// Detectors add the attributes of the host, process, os, container, and the pod from the k8s downward API env vars
+	Detectors []string `mapstructure:"detectors" default:"host,process,os,container,k8s" validate:"oneof=host process os container k8s"`
+	// InstanceID is the service.instance.id, generated when empty
+	InstanceID string `mapstructure:"instance_id"`
+}
+
+// k8sEnv maps the environment variables, usually set from the k8s downward API, to the resource attributes.
+var k8sEnv = map[string]func(string) attribute.KeyValue{
+	"K8S_POD_NAME":        semconv.K8SPodName,
+	"K8S_POD_UID":         semconv.K8SPodUID,
+	"K8S_NAMESPACE_NAME":  semconv.K8SNamespaceName,
+	"K8S_NODE_NAME":       semconv.K8SNodeName,
+	"K8S_DEPLOYMENT_NAME": semconv.K8SDeploymentName,
+	"K8S_CONTAINER_NAME":  semconv.K8SContainerName,
+}
+
+type k8sDetector struct{}
+
+func (k8sDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	for env, attr := range k8sEnv {
+		if v := os.Getenv(env); v != "" {
+			attrs = append(attrs, attr(v))
+		}
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// newResource returns the resource describing the service, from the lowest to the highest precedence:
+// resource.Default, the detectors, the name and version of i, OTEL_RESOURCE_ATTRIBUTES, the attributes from config,
+// then the service name and instance id from config.
+func newResource(ctx context.Context, cfg telemetryConfig, i Info) (*resource.Resource, error) {
+	opts := []resource.Option{resource.WithSchemaURL(semconv.SchemaURL)}
+	for _, d := range cfg.Resource.Detectors {
+		switch d {
+		case "host":
+			opts = append(opts, resource.WithHost())
+		case "process":
+			// the command arguments are left out, they may hold secrets
+			opts = append(opts,
+				resource.WithProcessPID(),
+				resource.WithProcessExecutableName(),
+				resource.WithProcessExecutablePath(),
+				resource.WithProcessOwner(),
+				resource.WithProcessRuntimeName(),
+				resource.WithProcessRuntimeVersion(),
+				resource.WithProcessRuntimeDescription(),
+			)
+		case "os":
+			opts = append(opts, resource.WithOS())
+		case "container":
+			opts = append(opts, resource.WithContainer())
+		case "k8s":
+			opts = append(opts, resource.WithDetectors(k8sDetector{}))
+		}
+	}
+
+	// the app info and a generated id are overridden by OTEL_RESOURCE_ATTRIBUTES
+	fallback := []attribute.KeyValue{semconv.ServiceName(i.Name), semconv.ServiceVersion(i.Version)}
+	instanceID := cfg.Resource.InstanceID
+	if instanceID == "" {
+		fallback = append(fallback, semconv.ServiceInstanceID(uuid.NewString()))
+	}
+	opts = append(opts,
+		resource.WithAttributes(fallback...),
+		resource.WithFromEnv(),
+		resource.WithAttributes(resourceAttributes(cfg.Resource.Attributes)...),
+	)
+
+	var service []attribute.KeyValue
+	if cfg.ServiceName != "" {
+		service = append(service, semconv.ServiceName(cfg.ServiceName))
+	}
+	if instanceID != "" {
+		service = append(service, semconv.ServiceInstanceID(instanceID))
+	}
+	opts = append(opts, resource.WithAttributes(service...))
+
+	res, err := resource.New(ctx, opts...)
+	if errors.Is(err, resource.ErrPartialResource) {
+		slog.DebugContext(ctx, "unable to detect some resource attributes", "error", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: telemetry.resource: %w", ErrConfig, err)
+	}
+	return resource.Merge(resource.Default(), res)
+}
+
+// resourceAttributes flattens the attributes, so keys holding dots may be written as nested keys.
+func resourceAttributes(m map[string]any) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	var flatten func(prefix string, m map[string]any)
+	flatten = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			if nested, ok := toStringMap(v); ok {
+				flatten(joinKey(prefix, k), nested)
+				continue
+			}
+			key := joinKey(prefix, k)
+			switch v := v.(type) {
+			case bool:
+				attrs = append(attrs, attribute.Bool(key, v))
+			case int:
+				attrs = append(attrs, attribute.Int(key, v))
+			case int64:
+				attrs = append(attrs, attribute.Int64(key, v))
+			case float64:
+				attrs = append(attrs, attribute.Float64(key, v))
+			default:
+				attrs = append(attrs, attribute.String(key, fmt.Sprint(v)))
+			}
+		}
+	}
+	flatten("", m)
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
@@ -0,0 +1,222 @@
+package app
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	jsonSchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+	durationPattern   = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	hostPortPattern   = `^[^:]*:[0-9]+$|^\[[^\]]+\]:[0-9]+$`
+)
+
+// ConfigSchema returns a JSON Schema of the config, describing the config types registered with RegisterConfig.
+// Keys that are not registered are allowed.
+func ConfigSchema() map[string]any {
+	return defaultApp.ConfigSchema()
+}
+
+func (a *App) ConfigSchema() map[string]any {
+	root := map[string]any{
+		"$schema":    jsonSchemaVersion,
+		"type":       "object",
+		"properties": map[string]any{},
+	}
+	for _, t := range a.configTypes.types() {
+		schema := typeSchema(t.typ)
+		parent, wildcard := strings.CutSuffix(t.key, ".*")
+		if wildcard {
+			schema = map[string]any{"type": "object", "additionalProperties": schema}
+		}
+		if parent == "" {
+			for k, v := range schema {
+				if k != "type" {
+					root[k] = v
+				}
+			}
+			continue
+		}
+
+		// nested keys, eg: server.http, are described by objects allowing other keys
+		node := root
+		parts := strings.Split(parent, ".")
+		for _, p := range parts[:len(parts)-1] {
+			props := node["properties"].(map[string]any)
+			next, ok := props[p].(map[string]any)
+			if !ok {
+				next = map[string]any{"type": "object", "properties": map[string]any{}}
+				props[p] = next
+			}
+			if _, ok := next["properties"]; !ok {
+				next["properties"] = map[string]any{}
+			}
+			node = next
+		}
+		node["properties"].(map[string]any)[parts[len(parts)-1]] = schema
+	}
+	return root
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	case t == reflect.TypeOf(time.Time{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(Secret("")):
+		return map[string]any{"type": "string", "writeOnly": true}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+func structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	additional := false
+	for _, f := range configFields(t) {
+		if f.remain {
+			additional = true
+			continue
+		}
+		schema := typeSchema(f.field.Type)
+		if def, ok := f.field.Tag.Lookup("default"); ok {
+			schema["default"] = schemaValue(schema, def)
+		}
+		if applyRules(schema, f.field.Type, f.field.Tag.Get("validate")) {
+			required = append(required, f.name)
+		}
+		props[f.name] = schema
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": additional,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// applyRules adds the validate rules to schema, and returns whether the field is required.
+func applyRules(schema map[string]any, t reflect.Type, rules string) bool {
+	required := false
+	typ, _ := schema["type"].(string)
+	for _, r := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(r), "=")
+		switch name {
+		case "required":
+			required = true
+			switch typ {
+			case "string":
+				schema["minLength"] = 1
+			case "array":
+				schema["minItems"] = 1
+			case "object":
+				schema["minProperties"] = 1
+			}
+		case "min", "max":
+			// durations are written as strings, their bounds can not be described
+			if t == reflect.TypeOf(time.Duration(0)) {
+				continue
+			}
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			keyword := map[string]string{"string": "Length", "array": "Items", "object": "Properties"}[typ]
+			if keyword == "" {
+				keyword = map[string]string{"min": "minimum", "max": "maximum"}[name]
+			} else {
+				keyword = name + keyword
+			}
+			schema[keyword] = n
+		case "oneof":
+			target := schema
+			if items, ok := schema["items"].(map[string]any); ok && typ == "array" {
+				target = items
+			}
+			var values []any
+			for _, v := range strings.Fields(param) {
+				values = append(values, schemaValue(target, v))
+			}
+			target["enum"] = values
+		case "url":
+			schema["format"] = "uri"
+		case "hostport":
+			schema["pattern"] = hostPortPattern
+		case "duration":
+			schema["pattern"] = durationPattern
+		}
+	}
+	return required
+}
+
+// schemaValue converts a value from a struct tag to the JSON type of schema.
+func schemaValue(schema map[string]any, s string) any {
+	switch schema["type"] {
+	case "integer":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		var values []any
+		for _, v := range strings.Split(s, ",") {
+			values = append(values, schemaValue(items, strings.TrimSpace(v)))
+		}
+		return values
+	}
+	return s
+}
+
+func (a *App) configSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the config",
+		Args:  cobra.NoArgs,
+		// the schema is built from the registered config types, the config is not needed
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return writeFormatted(cmd.OutOrStdout(), "json", a.ConfigSchema())
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+)
+
+func TestConfigSchema(t *testing.T) {
+	var stdout bytes.Buffer
+	a := app.New(
+		app.WithRootCommand(&cobra.Command{Use: "test"}),
+		app.WithConfigType[testServerConfig]("test.servers.*"),
+		app.WithArgs("config", "schema"),
+		app.WithOutput(&stdout, &stdout),
+	)
+	if err := a.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path ...string) any {
+		var v any = schema
+		for _, p := range path {
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil
+			}
+			v = m[p]
+		}
+		return v
+	}
+	server := []string{"properties", "test", "properties", "servers", "additionalProperties"}
+	field := func(name string, path ...string) []string {
+		return append(append(append([]string{}, server...), "properties", name), path...)
+	}
+
+	expect := []struct {
+		path  []string
+		value any
+	}{
+		{[]string{"$schema"}, "https://json-schema.org/draft/2020-12/schema"},
+		{append(server, "additionalProperties"), false},
+		{append(server, "required"), []any{"name"}},
+		{field("name", "type"), "string"},
+		{field("name", "minLength"), 1.0},
+		{field("mode", "enum"), []any{"http", "grpc"}},
+		{field("mode", "default"), "http"},
+		{field("workers", "type"), "integer"},
+		{field("workers", "default"), 4.0},
+		{field("workers", "maximum"), 64.0},
+		{field("upstream", "format"), "uri"},
+		{field("timeout", "type"), "string"},
+		{field("peers", "additionalProperties", "properties", "weight", "type"), "integer"},
+		{field("retries", "items", "required"), []any{"addr"}},
+		{[]string{"properties", "telemetry", "properties", "exporters", "additionalProperties", "properties", "insecure", "type"}, "boolean"},
+		{[]string{"properties", "telemetry", "properties", "tracer", "properties", "propagators", "items", "enum"}, []any{"tracecontext", "baggage", "b3", "b3multi", "jaeger", "none"}},
+		{[]string{"properties", "telemetry", "properties", "tracer", "properties", "propagators", "default"}, []any{"tracecontext", "baggage", "b3multi"}},
+	}
+	for _, e := range expect {
+		if got := get(e.path...); !reflect.DeepEqual(got, e.value) {
+			t.Errorf("expect %v to be %#v, got %#v", e.path, e.value, got)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Redacted replaces the secrets when the config is printed or logged.
+const Redacted = "[REDACTED]"
+
+// SecretResolver returns the secret of a reference, eg: /run/secrets/pg for ${file:/run/secrets/pg}.
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretResolverFunc is a function used as a SecretResolver.
+type SecretResolverFunc func(ctx context.Context, ref string) (string, error)
+
+func (f SecretResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// Secret is a config value that is redacted when printed, logged or marshaled.
+type Secret string
+
+func (s Secret) String() string {
+	return Redacted
+}
+
+func (s Secret) GoString() string {
+	return strconv.Quote(Redacted)
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(Redacted)
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(Redacted), nil
+}
+
+// secretRef matches ${scheme:ref}, a reference preceded by another $ is kept as is, without the escaping $.
+var secretRef = regexp.MustCompile(`\$?\$\{([a-z][a-z0-9+.-]*):([^}]*)\}`)
+
+// RegisterSecretResolver makes references like ${scheme:ref} in config values resolved with r.
+// The env and file schemes are registered by default.
+func RegisterSecretResolver(scheme string, r SecretResolver) {
+	defaultApp.RegisterSecretResolver(scheme, r)
+}
+
+// RedactedConfig returns the settings of the current config, with the resolved secrets replaced by Redacted.
+func RedactedConfig(ctx context.Context) map[string]any {
+	return FromContext(ctx).redactConfig(ConfigFromContext(ctx))
+}
+
+func (a *App) RegisterSecretResolver(scheme string, r SecretResolver) {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+	a.secretResolvers[scheme] = r
+}
+
+func defaultSecretResolvers() map[string]SecretResolver {
+	return map[string]SecretResolver{
+		"env": SecretResolverFunc(func(ctx context.Context, name string) (string, error) {
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				return "", errors.New("environment variable is not set: " + name)
+			}
+			return v, nil
+		}),
+		"file": SecretResolverFunc(func(ctx context.Context, file string) (string, error) {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimRight(string(b), "\r\n"), nil
+		}),
+	}
+}
+
+// resolveSecrets returns a copy of cfg with the secret references resolved, and the keys holding secrets.
+func (a *App) resolveSecrets(ctx context.Context, cfg *viper.Viper) (*viper.Viper, []string, error) {
+	a.configMu.Lock()
+	resolvers := make(map[string]SecretResolver, len(a.secretResolvers))
+	for scheme, r := range a.secretResolvers {
+		resolvers[scheme] = r
+	}
+	a.configMu.Unlock()
+
+	var keys []string
+	var errs []error
+	var resolve func(key string, v any) any
+	resolve = func(key string, v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			res := make(map[string]any, len(v))
+			for k, item := range v {
+				res[k] = resolve(joinKey(key, k), item)
+			}
+			return res
+		case []any:
+			res := make([]any, len(v))
+			for i, item := range v {
+				res[i] = resolve(key, item)
+			}
+			return res
+		case string:
+			secret := false
+			s := secretRef.ReplaceAllStringFunc(v, func(ref string) string {
+				if strings.HasPrefix(ref, "$$") {
+					return ref[1:]
+				}
+				m := secretRef.FindStringSubmatch(ref)
+				r, ok := resolvers[m[1]]
+				if !ok {
+					errs = append(errs, fmt.Errorf("%s: no secret resolver for %s", key, m[1]))
+					return ref
+				}
+				value, err := r.Resolve(ctx, m[2])
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: resolve %s secret: %w", key, m[1], err))
+					return ref
+				}
+				secret = true
+				return value
+			})
+			if secret && (len(keys) == 0 || keys[len(keys)-1] != key) {
+				keys = append(keys, key)
+			}
+			return s
+		default:
+			return v
+		}
+	}
+
+	settings := resolve("", cfg.AllSettings()).(map[string]any)
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+	resolved := viper.New()
+	return resolved, keys, resolved.MergeConfigMap(settings)
+}
+
+// redactConfig returns the settings of cfg, with the resolved secrets and the values of the Secret fields
+// of the registered config types replaced by Redacted.
+func (a *App) redactConfig(cfg *viper.Viper) map[string]any {
+	if cfg == nil {
+		return map[string]any{}
+	}
+	settings := copyMap(cfg.AllSettings())
+	if l := a.loaded.Load(); l != nil && l.config == cfg {
+		for _, key := range l.secrets {
+			setPath(settings, key, Redacted)
+		}
+	}
+	for _, t := range a.configTypes.types() {
+		parent, wildcard := strings.CutSuffix(t.key, ".*")
+		v, ok := any(settings), true
+		if parent != "" {
+			v, ok = lookupPath(settings, parent)
+		}
+		m, isMap := toStringMap(v)
+		if !ok || !isMap {
+			continue
+		}
+		if !wildcard {
+			redactSecrets(t.typ, m)
+			continue
+		}
+		for _, item := range m {
+			if im, isMap := toStringMap(item); isMap {
+				redactSecrets(t.typ, im)
+			}
+		}
+	}
+	return settings
+}
+
+// redactSecrets replaces the values of raw decoded into a Secret by t, including the items of maps and slices.
+func redactSecrets(t reflect.Type, raw map[string]any) {
+	secretType := reflect.TypeFor[Secret]()
+	var redact func(t reflect.Type, v any) any
+	redact = func(t reflect.Type, v any) any {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch {
+		case t == secretType:
+			if v == nil {
+				return v
+			}
+			return Redacted
+		case structType(t) != nil:
+			if m, ok := toStringMap(v); ok {
+				redactSecrets(t, m)
+			}
+		case t.Kind() == reflect.Map:
+			if m, ok := toStringMap(v); ok {
+				for k, item := range m {
+					m[k] = redact(t.Elem(), item)
+				}
+			}
+		case t.Kind() == reflect.Slice:
+			if items, ok := v.([]any); ok {
+				res := make([]any, len(items))
+				for i, item := range items {
+					res[i] = redact(t.Elem(), item)
+				}
+				return res
+			}
+		}
+		return v
+	}
+
+	for _, f := range configFields(structType(t)) {
+		if v, ok := raw[f.name]; ok && !f.remain {
+			raw[f.name] = redact(f.field.Type, v)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+)
+
+func TestSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "pg")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SECRET_PASS", "from-env")
+
+	tests := []struct {
+		name   string
+		config string
+		key    string
+		expect string
+		secret bool
+		err    string
+	}{
+		{name: "env", config: "pass: ${env:TEST_SECRET_PASS}", key: "pass", expect: "from-env", secret: true},
+		{name: "file", config: "pass: ${file:" + secretFile + "}", key: "pass", expect: "from-file", secret: true},
+		{name: "embedded", config: "dsn: postgres://user:${env:TEST_SECRET_PASS}@db", key: "dsn", expect: "postgres://user:from-env@db", secret: true},
+		{name: "custom", config: "token: ${vault:kv/app#token}", key: "token", expect: "vault-kv/app#token", secret: true},
+		{name: "escaped", config: "template: $${env:HOME}", key: "template", expect: "${env:HOME}"},
+		{name: "plain", config: "user: user", key: "user", expect: "user"},
+		{name: "unknown scheme", config: "pass: ${aws:pg}", err: "pass: no secret resolver for aws"},
+		{name: "missing env", config: "pass: ${env:TEST_SECRET_MISSING}", err: "environment variable is not set: TEST_SECRET_MISSING"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			root := &cobra.Command{
+				Use: "test",
+				RunE: func(cmd *cobra.Command, args []string) error {
+					if got := app.ConfigFromContext(cmd.Context()).GetString(tt.key); got != tt.expect {
+						return fmt.Errorf("expect %q, got %q", tt.expect, got)
+					}
+					redacted := app.RedactedConfig(cmd.Context())[tt.key]
+					if tt.secret && redacted != app.Redacted {
+						return fmt.Errorf("expect %s to be redacted, got %v", tt.key, redacted)
+					}
+					if !tt.secret && redacted != tt.expect {
+						return fmt.Errorf("expect %s not to be redacted, got %v", tt.key, redacted)
+					}
+					return nil
+				},
+			}
+			a := app.New(
+				app.WithRootCommand(root),
+				app.WithConfigFile(configFile),
+				app.WithEnvFiles(),
+				app.WithArgs(),
+				app.WithOutput(io.Discard, io.Discard),
+			)
+			a.RegisterSecretResolver("vault", app.SecretResolverFunc(func(ctx context.Context, ref string) (string, error) {
+				return "vault-" + ref, nil
+			}))
+			err := a.Execute(context.Background())
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !errors.Is(err, app.ErrConfig) || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expect config error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestSecret(t *testing.T) {
+	s := app.Secret("password")
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("connect", "pass", s)
+	for _, out := range []string{fmt.Sprint(s), fmt.Sprintf("%v %s %#v", s, s, s), buf.String()} {
+		if strings.Contains(out, "password") || !strings.Contains(out, app.Redacted) {
+			t.Errorf("expect secret to be redacted, got %s", out)
+		}
+	}
+}
+
+type testSecretConfig struct {
+	User   string                `mapstructure:"user"`
+	Pass   app.Secret            `mapstructure:"pass"`
+	Tokens map[string]app.Secret `mapstructure:"tokens"`
+}
+
+func TestSecretFieldsRedacted(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	config := `secretfields:
+  example:
+    user: admin
+    pass: plain-password
+    tokens:
+      api: plain-token
+telemetry:
+  exporters:
+    collector:
+      endpoint: http://localhost:4318
+      headers:
+        Authorization: Bearer plain-header
+`
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"config", "show"},
+		{"config", "show", "-o", "json"},
+		{"config", "explain", "secretfields.example.pass"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			var stdout bytes.Buffer
+			a := app.New(
+				app.WithRootCommand(&cobra.Command{Use: "test"}),
+				app.WithConfigFile(configFile),
+				app.WithConfigType[testSecretConfig]("secretfields.*"),
+				app.WithEnvFiles(),
+				app.WithArgs(args...),
+				app.WithOutput(&stdout, io.Discard),
+			)
+			if err := a.Execute(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(stdout.String(), app.Redacted) {
+				t.Errorf("expect the secrets to be redacted, got:\n%s", stdout.String())
+			}
+			if strings.Contains(stdout.String(), "plain-") {
+				t.Errorf("expect no secret in the output, got:\n%s", stdout.String())
+			}
+			if args[1] == "show" && !strings.Contains(stdout.String(), "admin") {
+				t.Errorf("expect the other values to be shown, got:\n%s", stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+type ShutdownFunc func(ctx context.Context) error
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// telemetryShutdownShare is the share of the deadline, one fifth, reserved to stop the telemetry component,
+// so the telemetry of the other hooks is flushed even when they use up their time.
+const telemetryShutdownShare = 5
+
+type shutdownHook struct {
+	name  string
+	stage string
+	fn    ShutdownFunc
+}
+
+type shutdownResult struct {
+	name     string
+	err      error
+	timedOut bool
+	elapsed  time.Duration
+}
+
+// OnShutdown registers funcs to be called one by one when the application stops,
+// in the reverse order of registration.
+func OnShutdown(funcs ...ShutdownFunc) {
+	defaultApp.OnShutdown(funcs...)
+}
+
+// OnShutdownStage registers funcs in a named stage. Funcs within the same stage are called concurrently,
+// and the stage is ordered relative to other hooks by its latest registration.
+func OnShutdownStage(stage string, funcs ...ShutdownFunc) {
+	defaultApp.OnShutdownStage(stage, funcs...)
+}
+
+// SetShutdownTimeout sets the deadline for all shutdown hooks. It is overridden by shutdown.timeout in config.
+func SetShutdownTimeout(d time.Duration) {
+	defaultApp.SetShutdownTimeout(d)
+}
+
+func (a *App) OnShutdown(funcs ...ShutdownFunc) {
+	a.OnShutdownStage("", funcs...)
+}
+
+func (a *App) OnShutdownStage(stage string, funcs ...ShutdownFunc) {
+	a.shutdownMu.Lock()
+	defer a.shutdownMu.Unlock()
+	for _, fn := range funcs {
+		a.shutdownHooks = append(a.shutdownHooks, shutdownHook{name: funcName(fn), stage: stage, fn: fn})
+	}
+}
+
+func (a *App) SetShutdownTimeout(d time.Duration) {
+	a.shutdownMu.Lock()
+	defer a.shutdownMu.Unlock()
+	a.shutdownTimeout = d
+}
+
+func (a *App) shutdownDeadline() time.Duration {
+	if cfg := a.config.Load(); cfg != nil && cfg.IsSet("shutdown.timeout") {
+		return cfg.GetDuration("shutdown.timeout")
+	}
+	a.shutdownMu.Lock()
+	defer a.shutdownMu.Unlock()
+	return a.shutdownTimeout
+}
+
+func (a *App) shutdown(timeout time.Duration) error {
+	a.shutdownMu.Lock()
+	hooks := a.shutdownHooks
+	a.shutdownHooks = make([]shutdownHook, 0)
+	a.shutdownMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	results := make([]shutdownResult, 0, len(hooks))
+	steps := a.workers.shutdownSteps()
+	steps = append(steps, shutdownSteps(hooks)...)
+	var telemetry [][]shutdownHook
+	for _, step := range a.components.shutdownSteps() {
+		if step[0].name == "component "+TelemetryComponent {
+			telemetry = append(telemetry, step)
+			continue
+		}
+		steps = append(steps, step)
+	}
+
+	hooksCtx, cancelHooks := ctx, context.CancelFunc(func() {})
+	if len(telemetry) > 0 {
+		hooksCtx, cancelHooks = context.WithTimeout(ctx, timeout-timeout/telemetryShutdownShare)
+	}
+	defer cancelHooks()
+	for _, step := range steps {
+		results = append(results, runShutdownStep(hooksCtx, step)...)
+	}
+	// the telemetry is stopped last, with the rest of the deadline
+	for _, step := range telemetry {
+		results = append(results, runShutdownStep(ctx, step)...)
+	}
+	return shutdownSummary(results, time.Since(start))
+}
+
+// shutdownSteps groups hooks into steps that are executed sequentially.
+// Hooks without a stage get a step of their own, hooks sharing a stage are put into one step.
+// Workers are drained before, and components are stopped after all hooks, the telemetry component being the last.
+func shutdownSteps(hooks []shutdownHook) [][]shutdownHook {
+	steps := make([][]shutdownHook, 0)
+	stages := make(map[string]int)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if h.stage == "" {
+			steps = append(steps, []shutdownHook{h})
+			continue
+		}
+		idx, ok := stages[h.stage]
+		if !ok {
+			idx = len(steps)
+			stages[h.stage] = idx
+			steps = append(steps, nil)
+		}
+		steps[idx] = append(steps[idx], h)
+	}
+	return steps
+}
+
+func runShutdownStep(ctx context.Context, step []shutdownHook) []shutdownResult {
+	type done struct {
+		idx     int
+		err     error
+		elapsed time.Duration
+	}
+
+	results := make([]shutdownResult, len(step))
+	finished := make([]bool, len(step))
+	for i, h := range step {
+		results[i].name = h.name
+	}
+	if ctx.Err() != nil {
+		for i := range results {
+			results[i].timedOut = true
+		}
+		return results
+	}
+
+	ch := make(chan done, len(step))
+	for i, h := range step {
+		go func() {
+			start := time.Now()
+			err := callShutdown(ctx, h.fn)
+			ch <- done{idx: i, err: err, elapsed: time.Since(start)}
+		}()
+	}
+
+	for pending := len(step); pending > 0; pending-- {
+		select {
+		case d := <-ch:
+			results[d.idx].err = d.err
+			results[d.idx].elapsed = d.elapsed
+			finished[d.idx] = true
+		case <-ctx.Done():
+			for i := range results {
+				if !finished[i] {
+					results[i].timedOut = true
+				}
+			}
+			return results
+		}
+	}
+	return results
+}
+
+func callShutdown(ctx context.Context, fn ShutdownFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+func shutdownSummary(results []shutdownResult, elapsed time.Duration) error {
+	errs := make([]error, 0)
+	failed, timedOut := 0, 0
+	for _, r := range results {
+		switch {
+		case r.timedOut:
+			timedOut++
+			slog.Warn("shutdown hook timed out", "hook", r.name)
+			errs = append(errs, fmt.Errorf("%s: %w", r.name, context.DeadlineExceeded))
+		case r.err != nil:
+			failed++
+			slog.Error("shutdown hook failed", "hook", r.name, "error", r.err, "elapsed", r.elapsed)
+			errs = append(errs, fmt.Errorf("%s: %w", r.name, r.err))
+		}
+	}
+	if len(results) > 0 {
+		slog.Info("shutdown completed", "hooks", len(results), "failed", failed, "timed_out", timedOut, "elapsed", elapsed)
+	}
+	return errors.Join(errs...)
+}
+
+func funcName(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	return strings.TrimSuffix(f.Name(), "-fm")
+}
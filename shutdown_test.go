@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	var mu sync.Mutex
+	calls := make([]string, 0)
+	hook := func(name string) ShutdownFunc {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	hooks := []shutdownHook{
+		{name: "db", fn: hook("db")},
+		{name: "worker-a", stage: "workers", fn: hook("worker-a")},
+		{name: "worker-b", stage: "workers", fn: hook("worker-b")},
+		{name: "server", fn: hook("server")},
+	}
+
+	steps := shutdownSteps(hooks)
+	expect := [][]string{{"server"}, {"worker-b", "worker-a"}, {"db"}}
+	if len(steps) != len(expect) {
+		t.Fatalf("expect %v steps, got %v", len(expect), len(steps))
+	}
+	for i, step := range steps {
+		names := make([]string, 0, len(step))
+		for _, h := range step {
+			names = append(names, h.name)
+		}
+		if strings.Join(names, ",") != strings.Join(expect[i], ",") {
+			t.Errorf("\nstep #%v, expect %v, got %v", i+1, expect[i], names)
+		}
+	}
+
+	for _, step := range steps {
+		runShutdownStep(context.Background(), step)
+	}
+	if calls[0] != "server" || calls[3] != "db" {
+		t.Errorf("invalid call order: %v", calls)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	failure := errors.New("failure")
+	step := []shutdownHook{
+		{name: "ok", fn: func(ctx context.Context) error { return nil }},
+		{name: "fail", fn: func(ctx context.Context) error { return failure }},
+		{name: "hang", fn: func(ctx context.Context) error { select {} }},
+		{name: "panic", fn: func(ctx context.Context) error { panic("boom") }},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	results := runShutdownStep(ctx, step)
+	if results[0].err != nil || results[0].timedOut {
+		t.Errorf("expect ok to succeed, got %+v", results[0])
+	}
+	if !errors.Is(results[1].err, failure) {
+		t.Errorf("expect fail to return failure, got %+v", results[1])
+	}
+	if !results[2].timedOut {
+		t.Errorf("expect hang to time out, got %+v", results[2])
+	}
+	if results[3].err == nil {
+		t.Errorf("expect panic to be recovered as error, got %+v", results[3])
+	}
+
+	err := shutdownSummary(results, time.Second)
+	if !errors.Is(err, failure) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect summary to contain failure and deadline exceeded, got %v", err)
+	}
+
+	results = runShutdownStep(ctx, step[:1])
+	if !results[0].timedOut {
+		t.Error("expect hooks to be skipped after the deadline")
+	}
+}
+
+type stopRecorder struct {
+	stopped chan error
+}
+
+func (c *stopRecorder) Start(ctx context.Context) error  { return nil }
+func (c *stopRecorder) Health(ctx context.Context) error { return nil }
+
+func (c *stopRecorder) Stop(ctx context.Context) error {
+	c.stopped <- ctx.Err()
+	return nil
+}
+
+func TestShutdownFlushesTelemetry(t *testing.T) {
+	a := New()
+	telemetry := &stopRecorder{stopped: make(chan error, 1)}
+	if err := a.Register(TelemetryComponent, telemetry); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.components.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	a.OnShutdown(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	err := a.shutdown(100 * time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect the hanging hook to time out, got %v", err)
+	}
+	select {
+	case err := <-telemetry.stopped:
+		if err != nil {
+			t.Errorf("expect the telemetry to be stopped before the deadline, got %v", err)
+		}
+	default:
+		t.Error("expect the telemetry to be stopped")
+	}
+}
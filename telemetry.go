@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
+	"net/http"
 	"time"
 
 	slogmulti "github.com/samber/slog-multi"
@@ -13,101 +13,193 @@ import (
 	"go.opentelemetry.io/contrib/bridges/otelslog"
 	otelruntime "go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/propagation"
 	logsdk "go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 )
 
 type telemetryConfig struct {
-	ServiceName string `mapstructure:"service_name"`
+	ServiceName string         `mapstructure:"service_name"`
+	Resource    resourceConfig `mapstructure:"resource"`
 
-	Tracer observabilityConfig `mapstructure:"tracer"`
+	Tracer tracerConfig        `mapstructure:"tracer"`
 	Metric observabilityConfig `mapstructure:"metric"`
-	Logger observabilityConfig `mapstructure:"logger"`
+	Logger loggerConfig        `mapstructure:"logger"`
 
 	Exporters map[string]exporterConfig `mapstructure:"exporters"`
 }
 
 type observabilityConfig struct {
 	Exporter string `mapstructure:"exporter"`
+	// Exporters are used along with Exporter, eg: a prometheus exporter next to an otlp one
+	Exporters []string `mapstructure:"exporters"`
 }
 
-type exporterConfig struct {
-	OtelGRPCAddr string            `mapstructure:"otlp_grpc_host"`
-	Headers      map[string]string `mapstructure:"headers"`
-	Insecure     bool              `mapstructure:"insecure"`
+// exporterNames returns the names of the exporters used by the signal.
+func (c observabilityConfig) exporterNames() []string {
+	if c.Exporter == "" {
+		return c.Exporters
+	}
+	return append([]string{c.Exporter}, c.Exporters...)
+}
+
+type tracerConfig struct {
+	observabilityConfig `mapstructure:",squash"`
+
+	// Sampler follows OTEL_TRACES_SAMPLER, the traceidratio samplers sample SamplerRatio of the traces
+	Sampler      string  `mapstructure:"sampler" default:"parentbased_always_on" validate:"oneof=always_on always_off traceidratio parentbased_always_on parentbased_always_off parentbased_traceidratio"`
+	SamplerRatio float64 `mapstructure:"sampler_ratio" default:"1" validate:"min=0,max=1"`
+	// Propagators extract the trace context from the incoming requests, and inject it in all of their formats,
+	// none disables them
+	Propagators []string `mapstructure:"propagators" default:"tracecontext,baggage,b3multi" validate:"oneof=tracecontext baggage b3 b3multi jaeger none"`
+}
+
+// TelemetryComponent is the name of the component holding the OpenTelemetry providers.
+// Components that produce telemetry should depend on it, so the providers are flushed after they stop.
+const TelemetryComponent = "telemetry"
+
+type telemetry struct {
+	config    *viper.Viper
+	info      Info
+	shutdowns []ShutdownFunc
+	// metrics serves the prometheus exporter without addr on the admin server
+	metrics http.Handler
+	// levels are shared with the admin server, logLevel is the --log-level flag
+	levels   *logLevels
+	logLevel string
 }
 
-func initTelemetry(config *viper.Viper) error {
-	cfg := telemetryConfig{}
-	err := config.UnmarshalKey("telemetry", &cfg)
+func (t *telemetry) Start(ctx context.Context) error {
+	// without config, telemetry is configured from the environment variables
+	if t.config == nil {
+		t.config = viper.New()
+	}
+	fns, err := t.initTelemetry()
+	t.shutdowns = fns
 	if err != nil {
-		return fmt.Errorf("config.Unmarshal: %w", err)
+		_ = t.Stop(ctx)
+		return err
 	}
+	return nil
+}
 
-	commonResource := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.ServiceName(cfg.ServiceName),
-	)
+func (t *telemetry) Stop(ctx context.Context) error {
+	errs := make([]error, 0, len(t.shutdowns))
+	for _, fn := range t.shutdowns {
+		errs = append(errs, fn(ctx))
+	}
+	return errors.Join(errs...)
+}
 
-	for _, initFn := range []func(telemetryConfig, *resource.Resource) (ShutdownFunc, error){initTracer, initLogger, initMeter} {
+func (t *telemetry) Health(ctx context.Context) error {
+	return nil
+}
+
+func (t *telemetry) initTelemetry() ([]ShutdownFunc, error) {
+	// the OpenTelemetry environment variables fill the keys not set in config
+	config := viper.New()
+	if err := config.MergeConfigMap(map[string]any{"telemetry": otelEnvConfig(t.config)}); err != nil {
+		return nil, err
+	}
+	if settings, ok := t.config.Get("telemetry").(map[string]any); ok {
+		if err := config.MergeConfigMap(map[string]any{"telemetry": settings}); err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := DecodeConfig[telemetryConfig](config, "telemetry")
+	if err != nil {
+		return nil, err
+	}
+
+	commonResource, err := newResource(context.Background(), cfg, t.info)
+	if err != nil {
+		return nil, err
+	}
+
+	shutdowns := make([]ShutdownFunc, 0, 3)
+	for _, initFn := range []func(telemetryConfig, *resource.Resource) (ShutdownFunc, error){initTracer, t.initLogger, t.initMeter} {
 		fn, err := initFn(cfg, commonResource)
 		if err != nil {
-			return err
+			return shutdowns, err
 		}
 		if fn != nil {
-			OnShutdown(fn)
+			shutdowns = append(shutdowns, fn)
 		}
 	}
-	return nil
+	return shutdowns, nil
 }
 
-func initMeter(cfg telemetryConfig, commonResource *resource.Resource) (ShutdownFunc, error) {
-	if cfg.Metric.Exporter == "" {
+func (t *telemetry) initMeter(cfg telemetryConfig, commonResource *resource.Resource) (ShutdownFunc, error) {
+	names := cfg.Metric.exporterNames()
+	if len(names) == 0 {
 		return nil, nil
 	}
-	exp, ok := cfg.Exporters[cfg.Metric.Exporter]
-	if !ok {
-		return nil, errors.New("telemetry.metric.exporter not found: " + cfg.Metric.Exporter)
-	}
 
-	opts := []otlpmetricgrpc.Option{
-		otlpmetricgrpc.WithCompressor("gzip"),
+	// the readers are shut down by the provider, or on error before the provider is created
+	var readers []metric.Reader
+	var servers []ShutdownFunc
+	shutdown := func(ctx context.Context) error {
+		errs := make([]error, 0, len(readers)+len(servers))
+		for _, r := range readers {
+			errs = append(errs, r.Shutdown(ctx))
+		}
+		for _, fn := range servers {
+			errs = append(errs, fn(ctx))
+		}
+		return errors.Join(errs...)
 	}
+	for _, name := range names {
+		exp, err := cfg.exporterFor("metric", name)
+		if err != nil {
+			return shutdown, err
+		}
 
-	if exp.OtelGRPCAddr != "" {
-		opts = append(opts, otlpmetricgrpc.WithEndpoint(exp.OtelGRPCAddr))
-	}
-	if exp.Insecure {
-		opts = append(opts, otlpmetricgrpc.WithInsecure())
-	}
-	if len(exp.Headers) > 0 {
-		opts = append(opts, otlpmetricgrpc.WithHeaders(exp.Headers))
+		if exp.Type == exporterPrometheus {
+			reader, handler, err := newPrometheusReader()
+			if err != nil {
+				return shutdown, fmt.Errorf("initMeter: %w", err)
+			}
+			readers = append(readers, reader)
+
+			switch {
+			case exp.Addr != "":
+				fn, err := servePrometheus(exp.Addr, handler)
+				if err != nil {
+					return shutdown, fmt.Errorf("initMeter: %w", err)
+				}
+				servers = append(servers, fn)
+			case t.config.GetString("admin.addr") != "":
+				t.metrics = handler
+			default:
+				return shutdown, fmt.Errorf("%w: the prometheus exporter %s requires addr, or admin.addr to be served by the admin server", ErrConfig, name)
+			}
+			continue
+		}
+
+		exporter, err := newMetricExporter(context.Background(), exp)
+		if err != nil {
+			return shutdown, fmt.Errorf("initMeter: %w", err)
+		}
+		readers = append(readers, metric.NewPeriodicReader(
+			exporter,
+			// Default is 1m. Set to 3s for demonstrative purposes.
+			metric.WithInterval(3*time.Second),
+		))
 	}
 
-	exporter, err := otlpmetricgrpc.New(context.Background(), opts...)
-	if err != nil {
-		return nil, fmt.Errorf("initMeter: %w", err)
-	}
-
-	provider := metric.NewMeterProvider(
-		metric.WithReader(
-			metric.NewPeriodicReader(
-				exporter,
-				// Default is 1m. Set to 3s for demonstrative purposes.
-				metric.WithInterval(3*time.Second),
-			),
-		),
-		metric.WithResource(commonResource),
-	)
+	opts := []metric.Option{metric.WithResource(commonResource)}
+	for _, r := range readers {
+		opts = append(opts, metric.WithReader(r))
+	}
+	provider := metric.NewMeterProvider(opts...)
 	otel.SetMeterProvider(provider)
+	readers = nil
+	servers = append([]ShutdownFunc{provider.Shutdown}, servers...)
 
 	// DEFAULT metrics
 	// meter := otel.Meter("core/meter")
@@ -134,93 +226,121 @@ func initMeter(cfg telemetryConfig, commonResource *resource.Resource) (Shutdown
 
 	otelruntime.Start(otelruntime.WithMinimumReadMemStatsInterval(time.Second))
 
-	return provider.Shutdown, nil
+	return shutdown, nil
 }
 
-func initLogger(cfg telemetryConfig, commonResource *resource.Resource) (ShutdownFunc, error) {
-	var exporter logsdk.Exporter
-	var err error
-
-	handler := []slog.Handler{
-		slog.NewTextHandler(os.Stdout, nil),
+func (t *telemetry) initLogger(cfg telemetryConfig, commonResource *resource.Resource) (ShutdownFunc, error) {
+	if t.levels == nil {
+		t.levels = &logLevels{}
 	}
-	defer func() {
-		slog.SetDefault(slog.New(slogmulti.Fanout(handler...)))
-	}()
-
-	if cfg.Logger.Exporter == "" {
-		return nil, nil
+	level, loggers, err := cfg.Logger.levels(t.logLevel)
+	if err != nil {
+		return nil, err
 	}
-	exp, ok := cfg.Exporters[cfg.Logger.Exporter]
-	if !ok {
-		return nil, errors.New("telemetry.logger.exporter not found: " + cfg.Logger.Exporter)
+	w, err := cfg.Logger.open()
+	if err != nil {
+		return nil, err
 	}
-
-	opts := []otlploggrpc.Option{
-		otlploggrpc.WithCompressor("gzip"),
+	t.levels.set(level, loggers)
+	closeOutput := func(context.Context) error {
+		return w.Close()
 	}
 
-	if exp.OtelGRPCAddr != "" {
-		opts = append(opts, otlploggrpc.WithEndpoint(exp.OtelGRPCAddr))
-	}
-	if exp.Insecure {
-		opts = append(opts, otlploggrpc.WithInsecure())
-	}
-	if len(exp.Headers) > 0 {
-		opts = append(opts, otlploggrpc.WithHeaders(exp.Headers))
+	handler := []slog.Handler{
+		cfg.Logger.handler(w),
 	}
+	defer func() {
+		slog.SetDefault(slog.New(&levelHandler{levels: t.levels, next: slogmulti.Fanout(handler...)}))
+	}()
 
-	exporter, err = otlploggrpc.New(context.Background(), opts...)
-	if err != nil {
-		return nil, fmt.Errorf("initLogger: %w", err)
+	names := cfg.Logger.exporterNames()
+	if len(names) == 0 {
+		return closeOutput, nil
+	}
+	opts := []logsdk.LoggerProviderOption{logsdk.WithResource(commonResource)}
+	for _, name := range names {
+		exp, err := cfg.exporterFor("logger", name)
+		if err != nil {
+			return closeOutput, err
+		}
+		exporter, err := newLogExporter(context.Background(), exp)
+		if err != nil {
+			return closeOutput, fmt.Errorf("initLogger: %w", err)
+		}
+		opts = append(opts, logsdk.WithProcessor(logsdk.NewBatchProcessor(exporter)))
 	}
 
-	provider := logsdk.NewLoggerProvider(
-		logsdk.WithProcessor(logsdk.NewBatchProcessor(exporter)),
-		logsdk.WithResource(commonResource),
-	)
+	provider := logsdk.NewLoggerProvider(opts...)
 	global.SetLoggerProvider(provider)
 
 	handler = append(handler, otelslog.NewHandler("github.com/yeka-go/app"))
-	return provider.Shutdown, nil
+	return func(ctx context.Context) error {
+		return errors.Join(provider.Shutdown(ctx), w.Close())
+	}, nil
 }
 
 func initTracer(cfg telemetryConfig, commonResource *resource.Resource) (ShutdownFunc, error) {
-	if cfg.Tracer.Exporter == "" {
-		return nil, nil
-	}
-	exp, ok := cfg.Exporters[cfg.Tracer.Exporter]
-	if !ok {
-		return nil, errors.New("telemetry.tracer.exporter not found: " + cfg.Tracer.Exporter)
-	}
+	// the trace context is propagated even when the spans are not exported
+	otel.SetTextMapPropagator(newPropagator(cfg.Tracer.Propagators))
 
-	opts := []otlptracegrpc.Option{
-		otlptracegrpc.WithCompressor("gzip"),
-	}
-
-	if exp.OtelGRPCAddr != "" {
-		opts = append(opts, otlptracegrpc.WithEndpoint(exp.OtelGRPCAddr))
-	}
-	if exp.Insecure {
-		opts = append(opts, otlptracegrpc.WithInsecure())
+	names := cfg.Tracer.exporterNames()
+	if len(names) == 0 {
+		return nil, nil
 	}
-	if len(exp.Headers) > 0 {
-		opts = append(opts, otlptracegrpc.WithHeaders(exp.Headers))
+	opts := []trace.TracerProviderOption{
+		trace.WithResource(commonResource),
+		trace.WithSampler(newSampler(cfg.Tracer.Sampler, cfg.Tracer.SamplerRatio)),
 	}
-
-	ctx := context.Background()
-	exporter, err := otlptracegrpc.New(ctx, opts...)
-	if err != nil {
-		return nil, fmt.Errorf("initTracer: %w", err)
+	for _, name := range names {
+		exp, err := cfg.exporterFor("tracer", name)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := newSpanExporter(context.Background(), exp)
+		if err != nil {
+			return nil, fmt.Errorf("initTracer: %w", err)
+		}
+		opts = append(opts, trace.WithBatcher(exporter))
 	}
 
-	provider := trace.NewTracerProvider(
-		trace.WithBatcher(exporter),
-		trace.WithResource(commonResource),
-	)
+	provider := trace.NewTracerProvider(opts...)
 	otel.SetTracerProvider(provider)
 
-	otel.SetTextMapPropagator(b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
-
 	return provider.Shutdown, nil
 }
+
+func newSampler(name string, ratio float64) trace.Sampler {
+	switch name {
+	case "always_on":
+		return trace.AlwaysSample()
+	case "always_off":
+		return trace.NeverSample()
+	case "traceidratio":
+		return trace.TraceIDRatioBased(ratio)
+	case "parentbased_always_off":
+		return trace.ParentBased(trace.NeverSample())
+	case "parentbased_traceidratio":
+		return trace.ParentBased(trace.TraceIDRatioBased(ratio))
+	default:
+		return trace.ParentBased(trace.AlwaysSample())
+	}
+}
+
+func newPropagator(names []string) propagation.TextMapPropagator {
+	propagators := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "jaeger":
+			propagators = append(propagators, jaeger.Jaeger{})
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
@@ -0,0 +1,350 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/yeka-go/app"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// executeWithConfig executes root with args, and config written to a config file when it is not empty.
+func executeWithConfig(t *testing.T, root *cobra.Command, config string, args ...string) error {
+	t.Helper()
+	opts := []app.Option{
+		app.WithRootCommand(root),
+		app.WithEnvFiles(),
+		app.WithArgs(args...),
+		app.WithOutput(io.Discard, io.Discard),
+	}
+	if config != "" {
+		configFile := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		opts = append(opts, app.WithConfigFile(configFile))
+	}
+	return app.New(opts...).Execute(context.Background())
+}
+
+// telemetryCommand returns a root command producing the test-span span, the test.counter metric and the test-log log.
+func telemetryCommand() *cobra.Command {
+	return &cobra.Command{
+		Use: "test",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, span := otel.Tracer("test").Start(cmd.Context(), "test-span")
+			defer span.End()
+			counter, _ := otel.Meter("test").Int64Counter("test.counter")
+			counter.Add(ctx, 1)
+			slog.InfoContext(ctx, "test-log")
+		},
+	}
+}
+
+func TestTracerSamplingAndPropagators(t *testing.T) {
+	tests := []struct {
+		name     string
+		tracer   string
+		sampled  bool
+		headers  []string
+		excluded []string
+		err      string
+	}{
+		{
+			name:     "defaults",
+			sampled:  true,
+			headers:  []string{"Traceparent", "X-B3-Traceid"},
+			excluded: []string{"B3", "Uber-Trace-Id"},
+		},
+		{
+			name:     "always off",
+			tracer:   "sampler: always_off\n    propagators: [tracecontext]",
+			headers:  []string{"Traceparent"},
+			excluded: []string{"X-B3-Traceid"},
+		},
+		{
+			name:    "ratio",
+			tracer:  "sampler: traceidratio\n    sampler_ratio: 0\n    propagators: [b3, jaeger]",
+			headers: []string{"B3", "Uber-Trace-Id"},
+		},
+		{
+			name:   "unknown sampler",
+			tracer: "sampler: sometimes",
+			err:    "telemetry.tracer.sampler: must be one of",
+		},
+		{
+			name:   "unknown propagator",
+			tracer: "propagators: [tracecontext, xray]",
+			err:    `telemetry.tracer.propagators: must be one of tracecontext, baggage, b3, b3multi, jaeger, none, got "xray"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telemetryFile := filepath.Join(t.TempDir(), "telemetry.jsonl")
+			config := fmt.Sprintf(`telemetry:
+  tracer:
+    exporter: file
+    %s
+  exporters:
+    file:
+      type: file
+      path: %s
+`, tt.tracer, telemetryFile)
+
+			header := http.Header{}
+			root := &cobra.Command{
+				Use: "test",
+				Run: func(cmd *cobra.Command, args []string) {
+					ctx, span := otel.Tracer("test").Start(cmd.Context(), "test-span")
+					defer span.End()
+					otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+				},
+			}
+			err := executeWithConfig(t, root, config)
+			if tt.err != "" {
+				if !errors.Is(err, app.ErrConfig) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expect config error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, h := range tt.headers {
+				if header.Get(h) == "" {
+					t.Errorf("expect %s header, got %v", h, header)
+				}
+			}
+			for _, h := range tt.excluded {
+				if header.Get(h) != "" {
+					t.Errorf("expect no %s header, got %v", h, header)
+				}
+			}
+			b, err := os.ReadFile(telemetryFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exported := bytes.Contains(b, []byte(`"Name":"test-span"`)); exported != tt.sampled {
+				t.Errorf("expect the span to be exported: %v, got:\n%s", tt.sampled, b)
+			}
+		})
+	}
+}
+
+func TestTelemetryFromEnv(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		env    map[string]string
+		expect map[string]string
+		absent []string
+	}{
+		{
+			name: "without config, http/protobuf by default",
+			expect: map[string]string{
+				"/v1/traces":  "env-service",
+				"/v1/metrics": "test.counter",
+			},
+			absent: []string{"/v1/logs"},
+		},
+		{
+			name: "generic endpoint with a path",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "%s/otlp/",
+			},
+			expect: map[string]string{
+				"/otlp/v1/traces":  "env-service",
+				"/otlp/v1/metrics": "test.counter",
+			},
+			absent: []string{"/v1/traces", "/v1/metrics", "/otlp/v1/logs"},
+		},
+		{
+			name: "signal endpoint used as is",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "%s/otlp",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "%s/custom/traces",
+			},
+			expect: map[string]string{
+				"/custom/traces":   "env-service",
+				"/otlp/v1/metrics": "test.counter",
+			},
+			absent: []string{"/otlp/v1/traces"},
+		},
+		{
+			name:   "merged with config",
+			config: "telemetry:\n  service_name: config-service\n  tracer:\n    exporter: file\n  exporters:\n    file:\n      type: file\n      path: %s\n",
+			expect: map[string]string{
+				"/v1/metrics": "config-service",
+			},
+			absent: []string{"/v1/traces", "/v1/logs"},
+		},
+		{
+			name: "signal specific and unsupported values",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "%s/custom/traces",
+				"OTEL_TRACES_SAMPLER":                "jaeger_remote",
+				"OTEL_PROPAGATORS":                   "xray",
+			},
+			expect: map[string]string{
+				"/custom/traces": "env-service",
+				"/v1/metrics":    "test.counter",
+			},
+			absent: []string{"/v1/traces", "/v1/logs"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &fakeCollector{requests: make(map[string][][]byte)}
+			srv := httptest.NewServer(collector)
+			defer srv.Close()
+			t.Setenv("OTEL_SERVICE_NAME", "env-service")
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL)
+			t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-token=secret")
+			t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "5000")
+			t.Setenv("OTEL_TRACES_SAMPLER", "always_on")
+			t.Setenv("OTEL_LOGS_EXPORTER", "none")
+			for k, v := range tt.env {
+				t.Setenv(k, strings.ReplaceAll(v, "%s", srv.URL))
+			}
+
+			config := tt.config
+			if config != "" {
+				config = fmt.Sprintf(config, filepath.Join(t.TempDir(), "telemetry.jsonl"))
+			}
+			if err := executeWithConfig(t, telemetryCommand(), config); err != nil {
+				t.Fatal(err)
+			}
+
+			for path, content := range tt.expect {
+				if !collector.received(path, content) {
+					t.Errorf("expect %s to receive %q", path, content)
+				}
+			}
+			for _, path := range tt.absent {
+				if collector.received(path, "") {
+					t.Errorf("expect nothing sent to %s", path)
+				}
+			}
+		})
+	}
+}
+
+// spanResource returns the resource attributes of the span exported to file.
+func spanResource(t *testing.T, file string) map[string]any {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var span struct {
+		Resource []struct {
+			Key   string
+			Value struct{ Value any }
+		}
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(b), &span); err != nil {
+		t.Fatal(err)
+	}
+	attrs := make(map[string]any)
+	for _, kv := range span.Resource {
+		attrs[kv.Key] = kv.Value.Value
+	}
+	return attrs
+}
+
+func TestTelemetryResource(t *testing.T) {
+	t.Setenv("K8S_POD_NAME", "pod-1")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=env,region=eu")
+
+	telemetryFile := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	config := fmt.Sprintf(`telemetry:
+  service_name: shop-api
+  resource:
+    detectors: [process, k8s]
+    attributes:
+      deployment.environment.name: prod
+      service:
+        namespace: shop
+      team: payments
+  tracer: {exporter: file}
+  exporters:
+    file:
+      type: file
+      path: %s
+`, telemetryFile)
+	if err := executeWithConfig(t, telemetryCommand(), config); err != nil {
+		t.Fatal(err)
+	}
+	attrs := spanResource(t, telemetryFile)
+
+	expect := map[string]any{
+		"service.name":                "shop-api",
+		"service.namespace":           "shop",
+		"deployment.environment.name": "prod",
+		"team":                        "payments",
+		"region":                      "eu",
+		"k8s.pod.name":                "pod-1",
+		"telemetry.sdk.language":      "go",
+	}
+	for k, v := range expect {
+		if attrs[k] != v {
+			t.Errorf("expect %s to be %v, got %v", k, v, attrs[k])
+		}
+	}
+	for _, k := range []string{"service.instance.id", "process.pid"} {
+		if attrs[k] == nil {
+			t.Errorf("expect %s to be detected, got %v", k, attrs)
+		}
+	}
+	if _, ok := attrs["host.name"]; ok {
+		t.Errorf("expect the host detector to be disabled, got %v", attrs)
+	}
+}
+
+func TestTelemetryResourceServiceName(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceName string
+		env         string
+		expect      string
+	}{
+		{name: "app name", expect: "test"},
+		{name: "resource attributes", env: "service.name=env-service", expect: "env-service"},
+		{name: "config", serviceName: "config-service", env: "service.name=env-service", expect: "config-service"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_RESOURCE_ATTRIBUTES", tt.env)
+
+			telemetryFile := filepath.Join(t.TempDir(), "telemetry.jsonl")
+			config := fmt.Sprintf(`telemetry:
+  service_name: "%s"
+  tracer: {exporter: file}
+  exporters:
+    file:
+      type: file
+      path: %s
+`, tt.serviceName, telemetryFile)
+			if err := executeWithConfig(t, telemetryCommand(), config); err != nil {
+				t.Fatal(err)
+			}
+			if got := spanResource(t, telemetryFile)["service.name"]; got != tt.expect {
+				t.Errorf("expect service.name to be %s, got %v", tt.expect, got)
+			}
+		})
+	}
+}
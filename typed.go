@@ -0,0 +1,530 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// ConfigAs decodes the config under key into T, see DecodeConfig.
+func ConfigAs[T any](ctx context.Context, key string) (T, error) {
+	cfg := ConfigFromContext(ctx)
+	if cfg == nil {
+		cfg = viper.New()
+	}
+	return DecodeConfig[T](cfg, key)
+}
+
+// DecodeConfig decodes the config under key into T, or the whole config when key is empty.
+//
+// Decoding is strict: keys without a matching field are errors. Missing keys are set from the `default` tag,
+// then the fields are checked with the comma separated rules of the `validate` tag:
+//
+//	required     the value must not be empty
+//	min=n, max=n bounds of a number or a duration, or of the length of a string, slice or map
+//	oneof=a b c  the value must be one of the space separated values
+//	url          the value must be an absolute URL
+//	hostport     the value must be a host:port address
+//	duration     the value must be a duration, eg: 1m30s
+//
+// Rules other than required are skipped for keys missing from the config, a key set to an empty value
+// is still checked, eg: 0 violates min=1. All the violations are reported in a single
+// *ConfigValidationError, which wraps ErrConfig.
+func DecodeConfig[T any](cfg *viper.Viper, key string) (T, error) {
+	var v T
+	raw := cfg.AllSettings()
+	if key != "" {
+		raw, _ = toStringMap(cfg.Get(key))
+	}
+	raw = copyMap(raw)
+
+	t := reflect.TypeOf(v)
+	verr := &ConfigValidationError{}
+	if structType(t) != nil {
+		applyDefaults(t, raw)
+		checkUnknownKeys(verr, key, t, raw)
+	}
+	if len(verr.Violations) > 0 {
+		return v, verr
+	}
+
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           &v,
+	})
+	if err != nil {
+		return v, err
+	}
+	if err := dec.Decode(raw); err != nil {
+		addDecodeErrors(verr, key, err)
+		return v, verr
+	}
+
+	validateValue(verr, key, reflect.ValueOf(&v).Elem(), "", true, raw)
+	if len(verr.Violations) > 0 {
+		return v, verr
+	}
+	return v, nil
+}
+
+// configType is a config type registered with RegisterConfig.
+type configType struct {
+	key      string
+	typ      reflect.Type
+	validate ConfigValidator
+}
+
+// configRegistry holds the config types registered to an App.
+type configRegistry struct {
+	mu   sync.Mutex
+	list []configType
+}
+
+func (r *configRegistry) add(t configType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.list = append(r.list, t)
+}
+
+func (r *configRegistry) types() []configType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]configType{}, r.list...)
+}
+
+// RegisterConfig registers T as the type of the config under key, or of every entry under a key ending with .*,
+// eg: pgx.* for pgx.example and pgx.reporting. Registered configs are validated when the config is loaded or reloaded,
+// and by the config validate command.
+func RegisterConfig[T any](key string) {
+	registerConfig[T](defaultApp, key)
+}
+
+// WithConfigType registers T as the type of the config under key, see RegisterConfig.
+func WithConfigType[T any](key string) Option {
+	return func(a *App) {
+		registerConfig[T](a, key)
+	}
+}
+
+func registerConfig[T any](a *App, key string) {
+	validate := func(cfg *viper.Viper) error {
+		keys := []string{key}
+		if parent, ok := strings.CutSuffix(key, ".*"); ok {
+			keys = keys[:0]
+			for name := range cfg.GetStringMap(parent) {
+				keys = append(keys, parent+"."+name)
+			}
+			sort.Strings(keys)
+		}
+		verr := &ConfigValidationError{}
+		for _, k := range keys {
+			if k != "" && !cfg.IsSet(k) {
+				continue
+			}
+			if _, err := DecodeConfig[T](cfg, k); err != nil {
+				var e *ConfigValidationError
+				if !errors.As(err, &e) {
+					return err
+				}
+				verr.Violations = append(verr.Violations, e.Violations...)
+			}
+		}
+		if len(verr.Violations) > 0 {
+			return verr
+		}
+		return nil
+	}
+	a.configTypes.add(configType{key: key, typ: reflect.TypeFor[T](), validate: validate})
+}
+
+// ConfigViolation is a config value that does not satisfy its rules.
+type ConfigViolation struct {
+	Key     string
+	Message string
+}
+
+// ConfigValidationError lists all the violations found while decoding a config.
+type ConfigValidationError struct {
+	Violations []ConfigViolation
+}
+
+func (e *ConfigValidationError) add(key, msg string) {
+	e.Violations = append(e.Violations, ConfigViolation{Key: key, Message: msg})
+}
+
+func (e *ConfigValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		if v.Key == "" {
+			msgs = append(msgs, v.Message)
+			continue
+		}
+		msgs = append(msgs, v.Key+": "+v.Message)
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+func (e *ConfigValidationError) Unwrap() error {
+	return ErrConfig
+}
+
+// configField is a struct field as seen by mapstructure.
+type configField struct {
+	name   string
+	field  reflect.StructField
+	index  []int
+	remain bool
+}
+
+func structType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// configFields returns the fields of t, with the fields of squashed and embedded structs inlined.
+func configFields(t reflect.Type) []configField {
+	var fields []configField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		// the exported fields of an embedded struct are promoted, even when the struct is unexported
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "squash") || (f.Anonymous && name == "") {
+			if st := structType(f.Type); st != nil && f.Type.Kind() == reflect.Struct {
+				for _, sf := range configFields(st) {
+					sf.index = append([]int{i}, sf.index...)
+					fields = append(fields, sf)
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, configField{
+			name:   strings.ToLower(name),
+			field:  f,
+			index:  []int{i},
+			remain: strings.Contains(opts, "remain"),
+		})
+	}
+	return fields
+}
+
+func applyDefaults(t reflect.Type, raw map[string]any) {
+	t = structType(t)
+	for _, f := range configFields(t) {
+		if f.remain {
+			continue
+		}
+		value, ok := raw[f.name]
+		if def, hasDefault := f.field.Tag.Lookup("default"); hasDefault && (!ok || value == nil) {
+			raw[f.name] = def
+			continue
+		}
+		switch ft := f.field.Type; {
+		case structType(ft) != nil:
+			m, isMap := toStringMap(value)
+			if !ok {
+				m, isMap = make(map[string]any), true
+			}
+			if isMap {
+				applyDefaults(ft, m)
+				if ok || len(m) > 0 {
+					raw[f.name] = m
+				}
+			}
+		case ft.Kind() == reflect.Map && structType(ft.Elem()) != nil:
+			if m, isMap := toStringMap(value); isMap {
+				for k, item := range m {
+					if im, isMap := toStringMap(item); isMap {
+						applyDefaults(ft.Elem(), im)
+						m[k] = im
+					}
+				}
+				raw[f.name] = m
+			}
+		}
+	}
+}
+
+func checkUnknownKeys(verr *ConfigValidationError, path string, t reflect.Type, raw map[string]any) {
+	t = structType(t)
+	fields := make(map[string]configField)
+	for _, f := range configFields(t) {
+		if f.remain {
+			return
+		}
+		fields[f.name] = f
+	}
+	for _, k := range sortedKeys(raw) {
+		value := raw[k]
+		f, ok := fields[strings.ToLower(k)]
+		if !ok {
+			verr.add(joinKey(path, k), "unknown key")
+			continue
+		}
+		m, isMap := toStringMap(value)
+		if !isMap {
+			continue
+		}
+		switch ft := f.field.Type; {
+		case structType(ft) != nil:
+			checkUnknownKeys(verr, joinKey(path, k), ft, m)
+		case ft.Kind() == reflect.Map && structType(ft.Elem()) != nil:
+			for _, name := range sortedKeys(m) {
+				if im, isMap := toStringMap(m[name]); isMap {
+					checkUnknownKeys(verr, joinKey(joinKey(path, k), name), ft.Elem(), im)
+				}
+			}
+		}
+	}
+}
+
+// validateValue checks v and its fields against their rules, set tells whether v is in the config,
+// and raw is its undecoded value, to tell whether its fields are.
+func validateValue(verr *ConfigValidationError, path string, v reflect.Value, rules string, set bool, raw any) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if hasRule(rules, "required") {
+				verr.add(path, "is required")
+			}
+			return
+		}
+		v = v.Elem()
+	}
+	validateRules(verr, path, v, rules, set)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			return
+		}
+		m, _ := toStringMap(raw)
+		for _, f := range configFields(v.Type()) {
+			value, ok := m[f.name]
+			validateValue(verr, joinKey(path, f.name), v.FieldByIndex(f.index), f.field.Tag.Get("validate"), ok, value)
+		}
+	case reflect.Map:
+		if structType(v.Type().Elem()) == nil {
+			return
+		}
+		m, _ := toStringMap(raw)
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			name := fmt.Sprint(k.Interface())
+			validateValue(verr, joinKey(path, name), v.MapIndex(k), "", true, m[name])
+		}
+	case reflect.Slice, reflect.Array:
+		if structType(v.Type().Elem()) == nil {
+			return
+		}
+		items, _ := raw.([]any)
+		for i := range v.Len() {
+			var item any
+			if i < len(items) {
+				item = items[i]
+			}
+			validateValue(verr, joinKey(path, strconv.Itoa(i)), v.Index(i), "", true, item)
+		}
+	}
+}
+
+func hasRule(rules, name string) bool {
+	for _, r := range strings.Split(rules, ",") {
+		if strings.TrimSpace(r) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validateRules(verr *ConfigValidationError, path string, v reflect.Value, rules string, set bool) {
+	if rules == "" {
+		return
+	}
+	empty := v.IsZero() || ((v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.Len() == 0)
+	if empty && hasRule(rules, "required") {
+		verr.add(path, "is required")
+		return
+	}
+	if empty && !set {
+		return
+	}
+	for _, r := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(r), "=")
+		if msg := checkRule(v, name, param); msg != "" {
+			verr.add(path, msg)
+		}
+	}
+}
+
+// checkRule returns the violation of a rule by the value v, or an empty string.
+func checkRule(v reflect.Value, name, param string) string {
+	switch name {
+	case "", "required":
+		return ""
+	case "min", "max":
+		n, limit, err := ruleBound(v, param)
+		if err != nil {
+			return fmt.Sprintf("invalid %s rule: %v", name, err)
+		}
+		if name == "min" && n < limit {
+			return fmt.Sprintf("must be at least %s", param)
+		}
+		if name == "max" && n > limit {
+			return fmt.Sprintf("must be at most %s", param)
+		}
+	case "oneof":
+		// each item of a list is one of the values
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			for i := range v.Len() {
+				if msg := checkRule(v.Index(i), name, param); msg != "" {
+					return msg
+				}
+			}
+			return ""
+		}
+		s := fmt.Sprint(v.Interface())
+		for _, o := range strings.Fields(param) {
+			if s == o {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(strings.Fields(param), ", "), s)
+	case "url":
+		u, err := url.Parse(fmt.Sprint(v.Interface()))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Sprintf("must be an absolute URL, got %q", v.Interface())
+		}
+	case "hostport":
+		_, port, err := net.SplitHostPort(fmt.Sprint(v.Interface()))
+		if err == nil {
+			_, err = strconv.ParseUint(port, 10, 16)
+		}
+		if err != nil {
+			return fmt.Sprintf("must be a host:port address, got %q", v.Interface())
+		}
+	case "duration":
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			return ""
+		}
+		if _, err := time.ParseDuration(fmt.Sprint(v.Interface())); err != nil {
+			return fmt.Sprintf("must be a duration, got %q", v.Interface())
+		}
+	default:
+		return "unknown validation rule " + name
+	}
+	return ""
+}
+
+// ruleBound returns the value compared by min and max rules, and the limit parsed from param.
+func ruleBound(v reflect.Value, param string) (float64, float64, error) {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(param)
+		return float64(v.Int()), float64(d), err
+	}
+	limit, err := strconv.ParseFloat(param, 64)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), limit, err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), limit, err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), limit, err
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), limit, err
+	default:
+		return 0, 0, errors.New("unsupported type " + v.Type().String())
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// addDecodeErrors adds a violation for each field that mapstructure could not decode,
+// its name, eg: hosts[0] or options[name].port, being mapped to the config key.
+func addDecodeErrors(verr *ConfigValidationError, key string, err error) {
+	switch e := err.(type) {
+	case *mapstructure.DecodeError:
+		field := strings.NewReplacer("[", ".", "]", "").Replace(e.Name())
+		verr.add(joinKey(key, field), e.Unwrap().Error())
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			addDecodeErrors(verr, key, err)
+		}
+	case interface{ Unwrap() error }:
+		addDecodeErrors(verr, key, e.Unwrap())
+	default:
+		verr.add(key, err.Error())
+	}
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func toStringMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, true
+	case map[any]any:
+		res := make(map[string]any, len(m))
+		for k, v := range m {
+			res[fmt.Sprint(k)] = v
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+// copyMap deep copies the nested maps of m, so defaults are not written into the config.
+func copyMap(m map[string]any) map[string]any {
+	res := make(map[string]any, len(m))
+	for k, v := range m {
+		if vm, ok := toStringMap(v); ok {
+			v = copyMap(vm)
+		}
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,117 @@
+package app_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/yeka-go/app"
+)
+
+type testServerConfig struct {
+	Name     string                    `mapstructure:"name" validate:"required"`
+	Mode     string                    `mapstructure:"mode" default:"http" validate:"oneof=http grpc"`
+	Addr     string                    `mapstructure:"addr" default:"localhost:8080" validate:"hostport"`
+	Timeout  time.Duration             `mapstructure:"timeout" default:"30s" validate:"min=1s,max=1m"`
+	Workers  int                       `mapstructure:"workers" default:"4" validate:"min=1,max=64"`
+	Upstream string                    `mapstructure:"upstream" validate:"url"`
+	Backoff  string                    `mapstructure:"backoff" validate:"duration"`
+	Peers    map[string]testPeerConfig `mapstructure:"peers"`
+	TLS      struct{ Enabled bool }    `mapstructure:"tls"`
+	Labels   map[string]string         `mapstructure:"labels"`
+	Retries  []testPeerConfig          `mapstructure:"retries"`
+}
+
+type testPeerConfig struct {
+	Addr   string `mapstructure:"addr" validate:"required,hostport"`
+	Weight int    `mapstructure:"weight" default:"1"`
+}
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		expect func(t *testing.T, c testServerConfig)
+		errs   []string
+	}{
+		{
+			name: "defaults",
+			yaml: "server:\n  name: api\n  peers:\n    a:\n      addr: a:80\n",
+			expect: func(t *testing.T, c testServerConfig) {
+				if c.Mode != "http" || c.Addr != "localhost:8080" || c.Timeout != 30*time.Second || c.Workers != 4 {
+					t.Errorf("expect defaults to be applied, got %+v", c)
+				}
+				if c.Peers["a"].Weight != 1 {
+					t.Errorf("expect the default weight of peer a, got %d", c.Peers["a"].Weight)
+				}
+			},
+		},
+		{
+			name: "values",
+			yaml: "server:\n  name: api\n  mode: grpc\n  timeout: 5s\n  workers: \"8\"\n  upstream: http://example.com\n  tls:\n    enabled: true\n",
+			expect: func(t *testing.T, c testServerConfig) {
+				if c.Mode != "grpc" || c.Timeout != 5*time.Second || c.Workers != 8 || !c.TLS.Enabled {
+					t.Errorf("unexpected config %+v", c)
+				}
+			},
+		},
+		{
+			name: "unknown keys",
+			yaml: "server:\n  name: api\n  adr: localhost:80\n  peers:\n    a:\n      addr: a:80\n      wieght: 2\n",
+			errs: []string{"server.adr: unknown key", "server.peers.a.wieght: unknown key"},
+		},
+		{
+			name: "violations",
+			yaml: "server:\n  mode: udp\n  addr: localhost\n  timeout: 2m\n  workers: 0\n  upstream: example.com\n  backoff: soon\n  retries:\n  - weight: 2\n",
+			errs: []string{
+				"server.name: is required",
+				"server.mode: must be one of http, grpc",
+				"server.addr: must be a host:port address",
+				"server.timeout: must be at most 1m",
+				"server.workers: must be at least 1",
+				"server.upstream: must be an absolute URL",
+				"server.backoff: must be a duration",
+				"server.retries.0.addr: is required",
+			},
+		},
+		{
+			name: "decode errors",
+			yaml: "server:\n  name: api\n  timeout: soon\n  workers: many\n  peers:\n    a:\n      addr: a:80\n      weight: heavy\n",
+			errs: []string{
+				"server.timeout: time: invalid duration",
+				"server.workers: cannot parse value as 'int'",
+				"server.peers.a.weight: cannot parse value as 'int'",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := viper.New()
+			cfg.SetConfigType("yaml")
+			if err := cfg.ReadConfig(strings.NewReader(tt.yaml)); err != nil {
+				t.Fatal(err)
+			}
+			c, err := app.DecodeConfig[testServerConfig](cfg, "server")
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.expect(t, c)
+				if cfg.IsSet("server.addr") {
+					t.Error("expect defaults not to be written into the config")
+				}
+				return
+			}
+			if !errors.Is(err, app.ErrConfig) {
+				t.Fatalf("expect ErrConfig, got %v", err)
+			}
+			for _, msg := range tt.errs {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("expect %q in error, got %v", msg, err)
+				}
+			}
+		})
+	}
+}
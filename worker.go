@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+type WorkerFunc func(ctx context.Context) error
+
+type RestartPolicy int
+
+const (
+	RestartNever RestartPolicy = iota
+	RestartOnFailure
+	RestartAlways
+)
+
+type SuperviseOption func(w *worker)
+
+// WithRestartPolicy sets when a worker is restarted after it returns. Default is RestartOnFailure.
+func WithRestartPolicy(policy RestartPolicy) SuperviseOption {
+	return func(w *worker) {
+		w.policy = policy
+	}
+}
+
+// WithBackoff sets the delay before the first restart, doubled on each consecutive restart up to max.
+func WithBackoff(initial, max time.Duration) SuperviseOption {
+	return func(w *worker) {
+		w.initialBackoff = initial
+		w.maxBackoff = max
+	}
+}
+
+// WithMaxRestarts limits the number of restarts, 0 means unlimited.
+func WithMaxRestarts(n int) SuperviseOption {
+	return func(w *worker) {
+		w.maxRestarts = n
+	}
+}
+
+type worker struct {
+	name           string
+	fn             WorkerFunc
+	policy         RestartPolicy
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxRestarts    int
+}
+
+type supervisor struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	wg      sync.WaitGroup
+	pending []*worker
+	used    bool
+}
+
+// Go runs fn in a goroutine that is stopped when the application shuts down. fn is never restarted.
+func Go(name string, fn WorkerFunc) {
+	defaultApp.Go(name, fn)
+}
+
+// Supervise runs fn in a goroutine that is restarted according to its restart policy,
+// until the application shuts down. Panics are recovered and treated as failures.
+func Supervise(name string, fn WorkerFunc, opts ...SuperviseOption) {
+	defaultApp.Supervise(name, fn, opts...)
+}
+
+func (a *App) Go(name string, fn WorkerFunc) {
+	a.Supervise(name, fn, WithRestartPolicy(RestartNever))
+}
+
+func (a *App) Supervise(name string, fn WorkerFunc, opts ...SuperviseOption) {
+	w := &worker{
+		name:           name,
+		fn:             fn,
+		policy:         RestartOnFailure,
+		initialBackoff: time.Second,
+		maxBackoff:     30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	a.workers.run(w)
+}
+
+func (s *supervisor) start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx = ctx
+	for _, w := range s.pending {
+		s.spawn(w)
+	}
+	s.pending = nil
+}
+
+func (s *supervisor) run(w *worker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		s.pending = append(s.pending, w)
+		return
+	}
+	s.spawn(w)
+}
+
+func (s *supervisor) spawn(w *worker) {
+	s.used = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		w.loop(s.ctx)
+	}()
+}
+
+func (s *supervisor) wait(ctx context.Context) error {
+	s.wg.Wait()
+	return nil
+}
+
+// shutdownSteps returns a step waiting for all workers to return.
+func (s *supervisor) shutdownSteps() [][]shutdownHook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.used {
+		return nil
+	}
+	return [][]shutdownHook{{{name: "workers", fn: s.wait}}}
+}
+
+func (w *worker) loop(ctx context.Context) {
+	backoff := w.initialBackoff
+	for restarts := 0; ; restarts++ {
+		start := time.Now()
+		err := w.call(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			slog.ErrorContext(ctx, "worker failed", "worker", w.name, "error", err)
+		}
+		if w.policy == RestartNever || (w.policy == RestartOnFailure && err == nil) {
+			return
+		}
+		if w.maxRestarts > 0 && restarts >= w.maxRestarts {
+			slog.ErrorContext(ctx, "worker reached max restarts", "worker", w.name, "restarts", restarts)
+			return
+		}
+
+		if time.Since(start) > w.maxBackoff {
+			backoff = w.initialBackoff
+		}
+		slog.WarnContext(ctx, "restarting worker", "worker", w.name, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, w.maxBackoff)
+		recordRestart(ctx, w.name, err)
+	}
+}
+
+func (w *worker) call(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logPanic(r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return w.fn(ctx)
+}
+
+func recordRestart(ctx context.Context, name string, err error) {
+	counter, cerr := otel.Meter("github.com/yeka-go/app").Int64Counter(
+		"app.worker.restarts",
+		otelmetric.WithDescription("Number of times a supervised worker has been restarted"),
+	)
+	if cerr != nil {
+		slog.DebugContext(ctx, "unable to create worker restart counter", "error", cerr)
+		return
+	}
+	counter.Add(ctx, 1, otelmetric.WithAttributes(
+		attribute.String("worker", name),
+		attribute.Bool("failure", err != nil),
+	))
+}
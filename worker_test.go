@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerRestartPolicy(t *testing.T) {
+	failure := errors.New("failure")
+	testData := []struct {
+		Policy RestartPolicy
+		Err    error
+		Panic  bool
+		Expect int32
+	}{
+		{RestartNever, failure, false, 1},
+		{RestartOnFailure, nil, false, 1},
+		{RestartOnFailure, failure, false, 4},
+		{RestartOnFailure, nil, true, 4},
+		{RestartAlways, nil, false, 4},
+	}
+	for i, v := range testData {
+		var calls atomic.Int32
+		w := &worker{
+			name: "test",
+			fn: func(ctx context.Context) error {
+				calls.Add(1)
+				if v.Panic {
+					panic("boom")
+				}
+				return v.Err
+			},
+			policy:         v.Policy,
+			initialBackoff: time.Millisecond,
+			maxBackoff:     time.Millisecond,
+			maxRestarts:    3,
+		}
+		w.loop(context.Background())
+		if calls.Load() != v.Expect {
+			t.Errorf("\nscenario #%v, expect %v calls, got %v", i+1, v.Expect, calls.Load())
+		}
+	}
+}
+
+func TestSupervisorDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &supervisor{}
+
+	var stopped atomic.Bool
+	s.run(&worker{name: "loop", policy: RestartAlways, fn: func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		stopped.Store(true)
+		return ctx.Err()
+	}})
+	if len(s.shutdownSteps()) != 0 {
+		t.Error("expect no shutdown step before the supervisor is started")
+	}
+
+	s.start(ctx)
+	cancel()
+	for _, step := range s.shutdownSteps() {
+		runShutdownStep(context.Background(), step)
+	}
+	if !stopped.Load() {
+		t.Error("expect worker to be drained")
+	}
+}